@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// alignmentStats counts the messages of a sender per alignment class
+type alignmentStats struct {
+	Sender   string
+	Messages int
+	DKIM     map[string]int
+	SPF      map[string]int
+	// messages that were authenticated but not aligned (legitimate
+	// traffic that DMARC does not credit)
+	Unaligned int
+	// authenticated domains of the unaligned messages
+	Domains map[string]int
+}
+
+// alignmentSummary is the alignment breakdown per sender, the senders with
+// the most unaligned legitimate traffic first
+func alignmentSummary(results FeedbackResults) ([]string, []summaryRow) {
+	index := make(map[string]*alignmentStats)
+	for _, r := range results {
+		name := r.ServiceName()
+		s, exists := index[name]
+		if !exists {
+			s = &alignmentStats{
+				Sender:  name,
+				DKIM:    make(map[string]int),
+				SPF:     make(map[string]int),
+				Domains: make(map[string]int),
+			}
+			index[name] = s
+		}
+		s.Messages += r.Count
+		s.DKIM[r.DKIMAlignment()] += r.Count
+		s.SPF[r.SPFAlignment()] += r.Count
+		if r.Alignment() == dmarc.UnalignedPass {
+			s.Unaligned += r.Count
+			for _, d := range r.UnalignedDomains() {
+				s.Domains[d] += r.Count
+			}
+		}
+	}
+	senders := make([]*alignmentStats, 0, len(index))
+	for _, s := range index {
+		senders = append(senders, s)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		if senders[i].Unaligned != senders[j].Unaligned {
+			return senders[i].Unaligned > senders[j].Unaligned
+		}
+		if senders[i].Messages != senders[j].Messages {
+			return senders[i].Messages > senders[j].Messages
+		}
+		return senders[i].Sender < senders[j].Sender
+	})
+
+	rows := make([]summaryRow, 0, len(senders))
+	for _, s := range senders {
+		rows = append(rows, summaryRow{cells: []string{
+			s.Sender,
+			strconv.Itoa(s.Messages),
+			strconv.Itoa(s.Unaligned),
+			percent(s.DKIM[dmarc.AlignedPass], s.Messages),
+			percent(s.DKIM[dmarc.UnalignedPass], s.Messages),
+			percent(s.SPF[dmarc.AlignedPass], s.Messages),
+			percent(s.SPF[dmarc.UnalignedPass], s.Messages),
+			strings.Join(displayDomains(topKeys(s.Domains)), ", "),
+		}, query: queryTerm("service", s.Sender)})
+	}
+	return []string{
+		"service", "messages", "unaligned", "dkim aligned", "dkim unaligned",
+		"spf aligned", "spf unaligned", "unaligned domains",
+	}, rows
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// days of the rolling baseline, and the days of history required before
+// a day can be flagged
+const baselineDays = 14
+const minHistory = 7
+
+// minimum excess over the baseline (in messages) to flag a day, small
+// domains would raise alerts for a handful of messages otherwise
+const minExcess = 10
+
+// MAD to standard deviation ratio for normal data
+const madScale = 1.4826
+
+// Alert is a day whose volume (or failing volume) of a sender or a
+// header_from domain stands out of its baseline, or a reporter whose
+// reports stopped
+type Alert struct {
+	Day time.Time `json:"day"`
+	// sender, domain or reporter, and its name
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	// messages or failing (failing DMARC, transient errors and forwarded
+	// traffic aside), silent for a reporter (days without report)
+	Metric string `json:"metric"`
+	Value  int    `json:"value"`
+	// median and median absolute deviation of the previous days (median
+	// days between two reports for a reporter)
+	Baseline float64 `json:"baseline"`
+	MAD      float64 `json:"mad"`
+	// robust z-score of the day
+	Score float64 `json:"score"`
+	// filter selecting the last report of a reporter
+	lastReport string
+}
+
+func (a *Alert) String() string {
+	if a.Metric == "silent" {
+		return fmt.Sprintf("%s reporter %s: no report for %d days (a report every %.1f days)",
+			a.Day.Format(shortDateFormat), a.Key, a.Value, a.Baseline)
+	}
+	metric := "messages"
+	if a.Metric == "failing" {
+		metric = "failing messages"
+	}
+	return fmt.Sprintf("%s %s %s: %d %s, baseline %.1f (MAD %.1f), score %.1f",
+		a.Day.Format(shortDateFormat), a.Kind, a.Key, a.Value, metric, a.Baseline, a.MAD, a.Score)
+}
+
+// query selects the records behind the alert
+func (a *Alert) query() string {
+	if a.Kind == "reporter" {
+		return a.lastReport
+	}
+	field := "sender"
+	if a.Kind == "domain" {
+		field = "header_from"
+	}
+	q := queryTerm(field, a.Key) + " begin=" + a.Day.Format(shortDateFormat)
+	if a.Metric == "failing" {
+		q += " failure=permanent forwarded=no"
+	}
+	return q
+}
+
+// matches tells whether some records behind the alert satisfy the filter
+func (a *Alert) matches(results FeedbackResults, f *Filter) bool {
+	if f == nil || len(f.terms) == 0 {
+		return true
+	}
+	q, err := ParseFilter(a.query())
+	if err != nil {
+		return false
+	}
+	for _, r := range results.Filter(q) {
+		if f.Match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// spike is a value standing out of its baseline
+type spike struct {
+	index       int
+	median, mad float64
+	score       float64
+}
+
+// spikes returns the values standing out of the median of the previous
+// baselineDays values by more than threshold times their MAD (scaled to a
+// standard deviation, at least 1)
+func spikes(values []float64, threshold float64) []spike {
+	out := make([]spike, 0)
+	for i := minHistory; i < len(values); i++ {
+		start := i - baselineDays
+		if start < 0 {
+			start = 0
+		}
+		window := values[start:i]
+		m := median(window)
+		deviations := make([]float64, len(window))
+		for k, v := range window {
+			deviations[k] = math.Abs(v - m)
+		}
+		mad := median(deviations)
+		score := (values[i] - m) / math.Max(madScale*mad, 1)
+		if score >= threshold && values[i]-m >= minExcess {
+			out = append(out, spike{index: i, median: m, mad: mad, score: score})
+		}
+	}
+	return out
+}
+
+// DetectAnomalies looks for spikes in the daily volume and failing volume
+// of every sender and header_from domain, and for the reporters that
+// stopped sending reports (empty being their reports without record), the
+// most recent first
+func DetectAnomalies(results FeedbackResults, empty []*dmarc.Report, threshold float64) []*Alert {
+	alerts := make([]*Alert, 0)
+	var span seen
+	for _, r := range results {
+		span.Add(r)
+	}
+	for _, kind := range []string{"sender", "domain"} {
+		groups := make(map[string]FeedbackResults)
+		for _, r := range results {
+			key := r.Sender()
+			if kind == "domain" {
+				key = r.HeaderFrom
+			}
+			groups[key] = append(groups[key], r)
+		}
+		for key, members := range groups {
+			buckets := timeSeriesBetween(members, span.first, span.last, false)
+			direct := timeSeriesBetween(directResults(members), span.first, span.last, false)
+			total := make([]float64, len(buckets))
+			failing := make([]float64, len(buckets))
+			for i, b := range buckets {
+				total[i] = float64(b.Messages)
+				failing[i] = float64(direct[i].Messages - direct[i].DMARCPass - direct[i].Transient)
+			}
+			for _, metric := range []struct {
+				name   string
+				values []float64
+			}{{"messages", total}, {"failing", failing}} {
+				for _, s := range spikes(metric.values, threshold) {
+					alerts = append(alerts, &Alert{
+						Day:      buckets[s.index].start,
+						Kind:     kind,
+						Key:      key,
+						Metric:   metric.name,
+						Value:    int(metric.values[s.index]),
+						Baseline: s.median,
+						MAD:      s.mad,
+						Score:    s.score,
+					})
+				}
+			}
+		}
+	}
+	for _, c := range reporterCoverages(results, empty) {
+		if c.Stopped() {
+			alerts = append(alerts, &Alert{
+				Day:        c.Last.AddDate(0, 0, 1),
+				Kind:       "reporter",
+				Key:        c.Name,
+				Metric:     "silent",
+				Value:      c.Silent,
+				Baseline:   c.Cadence,
+				Score:      float64(c.Silent) / c.Cadence,
+				lastReport: c.reportQuery(c.Last),
+			})
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].Day.Equal(alerts[j].Day) {
+			return alerts[i].Day.After(alerts[j].Day)
+		}
+		return alerts[i].Score > alerts[j].Score
+	})
+	return alerts
+}
+
+// alertsSummary lists the anomalies, the most recent first
+func alertsSummary(results FeedbackResults, empty []*dmarc.Report) ([]string, []summaryRow) {
+	alerts := DetectAnomalies(results, empty, alertScore)
+	rows := make([]summaryRow, 0, len(alerts))
+	for _, a := range alerts {
+		key := a.Key
+		if a.Kind == "domain" {
+			key = dmarc.DisplayDomain(a.Key)
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			a.Day.Format(shortDateFormat),
+			a.Kind,
+			key,
+			a.Metric,
+			strconv.Itoa(a.Value),
+			fmt.Sprintf("%.1f", a.Baseline),
+			fmt.Sprintf("%.1f", a.MAD),
+			fmt.Sprintf("%.1f", a.Score),
+		}, query: a.query()})
+	}
+	return []string{"day", "kind", "name", "metric", "messages", "baseline", "mad", "score"}, rows
+}
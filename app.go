@@ -8,8 +8,10 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/situation-sh/tmarc/dmarc"
 )
 
 var baseStyle = lipgloss.NewStyle().
@@ -22,14 +24,19 @@ var baseStyle = lipgloss.NewStyle().
 // key.Map. It could also very easily be a map[string]key.Binding.
 type keyMap struct {
 	table.KeyMap
-	Scan key.Binding
-	Quit key.Binding
+	Scan   key.Binding
+	View   key.Binding
+	Filter key.Binding
+	Open   key.Binding
+	Expand key.Binding
+	Sort   key.Binding
+	Quit   key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
 // of the key.Map interface.
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Scan, k.Quit, k.LineUp, k.LineDown, k.PageDown, k.PageUp}
+	return []key.Binding{k.Scan, k.View, k.Filter, k.Open, k.Expand, k.Sort, k.Quit, k.LineUp, k.LineDown, k.PageDown, k.PageUp}
 }
 
 // FullHelp returns keybindings for the expanded help view. It's part of the
@@ -37,8 +44,11 @@ func (k keyMap) ShortHelp() []key.Binding {
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Scan, k.LineUp},
-		{k.Quit, k.LineDown},
-		{k.PageDown, k.PageUp},
+		{k.View, k.LineDown},
+		{k.Filter, k.PageDown},
+		{k.Open, k.PageUp},
+		{k.Expand, k.Quit},
+		{k.Sort},
 		// {k.LineUp, k.LineUp, k.LineUp},
 		// {k.LineUp, k.LineDown, k.PageDown, k.PageUp}, // second column
 	}
@@ -67,6 +77,19 @@ type model struct {
 	help     help.Model
 	results  FeedbackResults
 	updating bool
+	// every scanned result (results are the ones matching the filter)
+	all FeedbackResults
+	// every scanned report, and the ones without record matching the filter
+	reports   []*dmarc.Report
+	empty     []*dmarc.Report
+	filter    *Filter
+	input     *textinput.Model
+	filtering bool
+	// aggregated views (the records view is the mode 0)
+	views []view
+	mode  int
+	// live SPF evaluations (see spfKey)
+	spf map[string]*SPFResult
 }
 
 func NewModel(directory string) model {
@@ -75,25 +98,58 @@ func NewModel(directory string) model {
 		dir = directory
 	}
 	scanner := NewScanner(dir)
-	results := scanner.rawScan()
+	results, reports := scanner.rawScan()
+	indexFirstSeen(results)
 
 	h := help.New()
 	// h.ShowAll = false
 	table := NewTable(results)
 	table.Focus()
-	return model{
+	input := textinput.New()
+	input.Prompt = "/"
+	input.Placeholder = "header_from=example.com dkim=fail count>10"
+	m := model{
 		scanner:  scanner,
-		header:   NewHeader(dir, results.Files(), results.Len()),
+		header:   NewHeader(dir, len(reports), results.Len()),
 		table:    &table,
 		viewer:   NewXMLViewer(),
 		help:     h,
 		results:  results,
 		updating: false,
+		all:      results,
+		reports:  reports,
+		empty:    reportsWithoutRecords(reports, nil),
+		input:    &input,
+		views: []view{
+			NewDashboard(),
+			NewReportsSummary("reports", reportsSummary),
+			NewReportsSummary("reporters", reportersSummary),
+			NewSummary("domains", domainsSummary),
+			NewSummary("services", servicesSummary).WithFetcher(fetchListings),
+			NewSummary("senders", sendersSummary).WithDetail(trendDetail),
+			NewSummary("policies", policiesSummary).WithFetcher(fetchLiveDMARC),
+			NewSummary("selectors", selectorsSummary).WithFetcher(fetchDKIMKeys),
+			NewSummary("networks", networksSummary).WithFetcher(fetchListings),
+			NewSummary("alignment", alignmentSummary),
+			NewSummary("overrides", overridesSummary),
+			NewSummary("sampling", samplingSummary),
+			NewSummary("readiness", readinessSummary),
+			NewSummary("spoofing", spoofingSummary).WithDetail(spoofingTrend),
+			NewComparison(),
+			NewReportsSummary("alerts", alertsSummary),
+			NewChart(),
+		},
+		spf: make(map[string]*SPFResult),
 	}
+	// land on the dashboard
+	m.mode = 1
+	m.header.view = m.viewName()
+	m.refreshView()
+	return m
 }
 
 func (m model) keys() keyMap {
-	return keyMap{
+	k := keyMap{
 		KeyMap: m.table.KeyMap,
 		Quit: key.NewBinding(
 			key.WithKeys("q", "esc", "ctrl+c"),
@@ -102,14 +158,137 @@ func (m model) keys() keyMap {
 		Scan: key.NewBinding(
 			key.WithKeys("s"),
 			key.WithHelp("s", "scan directory"),
+		),
+		View: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "switch view"),
+		),
+		Filter: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "filter"),
+		),
+		Open: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "show records"),
+		),
+		Expand: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "expand"),
+		),
+		Sort: key.NewBinding(
+			key.WithKeys("o", "O"),
+			key.WithHelp("o/O", "sort/reverse"),
 		)}
+	// only the aggregated views can be opened, and only the tables
+	// expanded and sorted
+	k.Open.SetEnabled(m.mode > 0)
+	_, table := m.currentView().(tabular)
+	k.Expand.SetEnabled(table)
+	k.Sort.SetEnabled(table)
+	return k
 }
 
 func (m model) selected() *FeedbackResult {
 	selected := m.table.Cursor()
+	if selected < 0 || selected >= len(m.results) {
+		return nil
+	}
 	return m.results[selected]
 }
 
+// viewName returns the name of the current view
+func (m model) viewName() string {
+	if m.mode == 0 {
+		return "records"
+	}
+	return m.views[m.mode-1].Name()
+}
+
+// currentView returns the aggregated view displayed (nil for the records)
+func (m model) currentView() view {
+	if m.mode == 0 {
+		return nil
+	}
+	return m.views[m.mode-1]
+}
+
+// nextView switches to the next view and refreshes it
+func (m *model) nextView() tea.Cmd {
+	m.mode = (m.mode + 1) % (len(m.views) + 1)
+	m.header.view = m.viewName()
+	m.refreshView()
+	if m.mode > 0 {
+		return m.views[m.mode-1].Fetch(m.results)
+	}
+	return nil
+}
+
+// refreshView rebuilds the current aggregated view
+func (m *model) refreshView() {
+	if m.mode > 0 {
+		if v, ok := m.views[m.mode-1].(reportsView); ok {
+			v.SetReports(m.empty)
+		}
+		m.views[m.mode-1].SetResults(m.results)
+	}
+}
+
+// refreshTable rebuilds the records table (its columns may change)
+func (m *model) refreshTable() {
+	cursor := m.table.Cursor()
+	t := NewTable(m.results)
+	t.SetHeight(m.table.Height())
+	if cursor < len(m.results) {
+		t.SetCursor(cursor)
+	}
+	if m.table.Focused() {
+		t.Focus()
+	} else {
+		t.Blur()
+	}
+	m.table = &t
+}
+
+// applyFilter selects the results matching the filter and refreshes
+// the table and the current view
+func (m *model) applyFilter() {
+	m.results = m.all.Filter(m.filter)
+	m.empty = reportsWithoutRecords(m.reports, m.filter)
+	m.header.records = m.results.Len()
+	m.header.filter = ""
+	if m.filter != nil {
+		m.header.filter = m.filter.Query
+	}
+	m.refreshTable()
+	m.refreshView()
+}
+
+// updateFilter handles the keys while the filter is edited
+func (m *model) updateFilter(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "enter":
+		f, err := ParseFilter(m.input.Value())
+		if err != nil {
+			m.header.err = err
+			return nil
+		}
+		m.header.err = nil
+		m.filter = f
+		m.filtering = false
+		m.input.Blur()
+		m.applyFilter()
+		return m.Show
+	case "esc":
+		m.filtering = false
+		m.header.err = nil
+		m.input.Blur()
+		return nil
+	}
+	t, cmd := m.input.Update(msg)
+	m.input = &t
+	return cmd
+}
+
 func (m model) nextFocus() {
 	if m.table.Focused() {
 		m.table.Blur()
@@ -127,19 +306,37 @@ func (m model) resize(width, height int) {
 	m.header.width = width
 	m.viewer.SetHeight(height - 7)
 	m.viewer.SetWidth(width - m.table.Width())
+	for i := range m.views {
+		m.views[i].SetSize(width, height-8)
+	}
 }
 
 func (m model) Show() tea.Msg {
 	selected := m.selected()
-	xmlMsg := ShowXMLRecordMsg(string(selected.XML))
+	if selected == nil {
+		return tea.Msg(ShowXMLRecordMsg(""))
+	}
+	xmlMsg := ShowXMLRecordMsg(recordDetail(selected, m.spf[spfKey(selected)]))
 	return tea.Msg(xmlMsg)
 }
 
+// checkSelected evaluates SPF for the selected line if not done yet
+func (m model) checkSelected() tea.Cmd {
+	selected := m.selected()
+	if offline || selected == nil || selected.SPFDomain() == "" {
+		return nil
+	}
+	if _, done := m.spf[spfKey(selected)]; done {
+		return nil
+	}
+	return checkSPF(selected)
+}
+
 func (m model) Init() tea.Cmd {
 	m.table.Focus()
 	m.viewer.Blur()
-	// display the xml of the selected line
-	return m.Show
+	// display the details of the selected line
+	return tea.Batch(m.Show, m.checkSelected(), fetchListings(m.all))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -158,10 +355,42 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case ShowXMLRecordMsg:
 		m.viewer, cmd = m.viewer.Update(msg)
 		cmds = append(cmds, cmd)
+	case DrillDownMsg:
+		// narrow the current filter and show the records
+		query := string(msg)
+		if m.filter != nil {
+			query = m.filter.Query + " " + query
+		}
+		f, err := ParseFilter(query)
+		if err != nil {
+			m.header.err = err
+			break
+		}
+		m.filter = f
+		m.input.SetValue(f.Query)
+		m.mode = 0
+		m.header.view = m.viewName()
+		m.applyFilter()
+		cmds = append(cmds, m.Show, m.checkSelected())
+	case RefreshViewMsg:
+		// the filter may depend on the new data (listed=yes)
+		m.applyFilter()
+	case SPFCheckMsg:
+		m.spf[msg.key] = msg.result
+		if selected := m.selected(); selected != nil && spfKey(selected) == msg.key {
+			cmds = append(cmds, m.Show)
+		}
 	case ScanResultsMsg:
 		// receive results from scanner
-		_, rows := toTable(FeedbackResults(msg))
-		m.table.SetRows(rows)
+		m.all = msg.results
+		indexFirstSeen(m.all)
+		m.reports = msg.reports
+		m.header.files = len(msg.reports)
+		m.applyFilter()
+		cmds = append(cmds, fetchListings(m.all), m.Show)
+		if m.mode > 0 {
+			cmds = append(cmds, m.views[m.mode-1].Fetch(m.results))
+		}
 		m.updating = false
 		m.header.showSpinner = false
 	case ScanTriggerMsg:
@@ -179,24 +408,48 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.header.width = msg.Width
 		m.viewer.SetHeight(msg.Height - 7)
 		m.viewer.SetWidth(msg.Width - m.table.Width())
+		for i := range m.views {
+			m.views[i].SetSize(msg.Width, msg.Height-8)
+		}
 		return m, tea.ClearScreen
 	case tea.KeyMsg:
+		if m.filtering {
+			cmds = append(cmds, m.updateFilter(msg))
+			break
+		}
 		switch msg.String() {
-		case "q", "esc", "ctrl+c":
+		case "esc":
+			// clear the filter first
+			if m.filter != nil {
+				m.filter = nil
+				m.input.SetValue("")
+				m.applyFilter()
+				return m, m.Show
+			}
+			return m, tea.Quit
+		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "/":
+			m.filtering = true
+			m.input.CursorEnd()
+			cmds = append(cmds, m.input.Focus())
 		case "tab":
-			m.nextFocus()
+			if m.mode == 0 {
+				m.nextFocus()
+			}
+		case "v":
+			cmds = append(cmds, m.nextView())
 		case "s":
 			var msg ScanTriggerMsg
 			return m, func() tea.Msg { return tea.Msg(msg) }
 		default:
-			if m.table.Focused() {
+			if m.mode > 0 {
+				m.views[m.mode-1], cmd = m.views[m.mode-1].Update(msg)
+				cmds = append(cmds, cmd)
+			} else if m.table.Focused() {
 				tbl, cmd = m.table.Update(msg)
 				m.table = &tbl
-				selected := m.selected()
-				xmlMsg := ShowXMLRecordMsg(string(selected.XML))
-				cmd2 := func() tea.Msg { return tea.Msg(xmlMsg) }
-				cmds = append(cmds, cmd, cmd2)
+				cmds = append(cmds, cmd, m.Show, m.checkSelected())
 			} else {
 				m.viewer, cmd = m.viewer.Update(msg)
 				cmds = append(cmds, cmd)
@@ -218,7 +471,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m model) View() string {
 	v := m.header.View()
-	if m.viewer.Width() < 25 {
+	if m.filtering {
+		v += " " + m.input.View() + "\n"
+	}
+	if m.mode > 0 {
+		v += m.views[m.mode-1].View() + "\n"
+	} else if m.viewer.Width() < 25 {
 		v += RenderTable(m.table) + "\n"
 	} else {
 		v += lipgloss.JoinHorizontal(lipgloss.Top, RenderTable(m.table), m.viewer.View()) + "\n"
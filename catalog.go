@@ -0,0 +1,164 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/situation-sh/tmarc/dmarc"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed services.yaml
+var builtinServices []byte
+
+// Service is a known email service provider (ESP, hosted mailbox, relay...).
+// A source is attributed to a service if its IP lies in one of the CIDRs,
+// if its reverse DNS name ends with one of the rdns suffixes or if one of
+// the DKIM signatures that passed comes from one of the dkim domains.
+type Service struct {
+	Name  string   `json:"name" yaml:"name"`
+	CIDRs []string `json:"cidrs" yaml:"cidrs"`
+	RDNS  []string `json:"rdns" yaml:"rdns"`
+	DKIM  []string `json:"dkim" yaml:"dkim"`
+
+	networks []*net.IPNet
+}
+
+// Catalog is an ordered list of services. The first match wins.
+type Catalog struct {
+	Services []*Service `json:"services" yaml:"services"`
+}
+
+// ParseCatalog reads a catalog in YAML or JSON (YAML being a superset of
+// JSON, a single decoder handles both).
+func ParseCatalog(data []byte) (*Catalog, error) {
+	c := Catalog{}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	for _, s := range c.Services {
+		if s.Name == "" {
+			return nil, fmt.Errorf("service without name")
+		}
+		for _, cidr := range s.CIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %v", s.Name, err)
+			}
+			s.networks = append(s.networks, network)
+		}
+		for i, suffix := range s.RDNS {
+			s.RDNS[i] = normalizeName(suffix)
+		}
+		for i, domain := range s.DKIM {
+			s.DKIM[i] = normalizeName(domain)
+		}
+	}
+	return &c, nil
+}
+
+// LoadCatalog reads a user catalog from a YAML or JSON file.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data)
+}
+
+// DefaultCatalog returns the catalog shipped with tmarc.
+func DefaultCatalog() *Catalog {
+	c, err := ParseCatalog(builtinServices)
+	if err != nil {
+		// the embedded file is part of the build, it must be valid
+		panic(err)
+	}
+	return c
+}
+
+// Extend puts the services of other before the ones of c, so that user
+// definitions take precedence over the built-in ones.
+func (c *Catalog) Extend(other *Catalog) {
+	services := make([]*Service, 0, len(other.Services)+len(c.Services))
+	services = append(services, other.Services...)
+	c.Services = append(services, c.Services...)
+}
+
+// Match returns the name of the first service matching the source
+// (empty string if none), every service being checked on its CIDRs, rdns
+// and dkim rules before the next one. dkimDomains must only contain the
+// domains of the signatures that passed, otherwise anybody could claim to
+// be a service by adding a bogus signature.
+func (c *Catalog) Match(ip net.IP, rdns string, dkimDomains []string) string {
+	if c == nil {
+		return ""
+	}
+	name := normalizeName(rdns)
+	for _, s := range c.Services {
+		if s.matches(ip, name, dkimDomains) {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// matches tells whether the source matches one of the rules of the service
+// (rdns being normalized)
+func (s *Service) matches(ip net.IP, rdns string, dkimDomains []string) bool {
+	for _, network := range s.networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	if rdns != "" {
+		for _, suffix := range s.RDNS {
+			if hasDomainSuffix(rdns, suffix) {
+				return true
+			}
+		}
+	}
+	for _, d := range dkimDomains {
+		name := normalizeName(d)
+		for _, suffix := range s.DKIM {
+			if hasDomainSuffix(name, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeName returns the canonical form of a domain name (lowercased
+// A-labels, without trailing dot)
+func normalizeName(name string) string {
+	return dmarc.CanonicalDomain(name)
+}
+
+// displayDomains returns the Unicode form of domain names
+func displayDomains(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = dmarc.DisplayDomain(n)
+	}
+	return out
+}
+
+// domainLabel shows a domain in Unicode followed by its A-label when they
+// differ
+func domainLabel(name string) string {
+	if display := dmarc.DisplayDomain(name); display != normalizeName(name) {
+		return display + " (" + normalizeName(name) + ")"
+	}
+	return name
+}
+
+// hasDomainSuffix checks that name is suffix or a subdomain of suffix
+// (label-wise, so that "evilgoogle.com" does not match "google.com")
+func hasDomainSuffix(name, suffix string) bool {
+	return name == suffix || strings.HasSuffix(name, "."+suffix)
+}
+
+var catalog = DefaultCatalog()
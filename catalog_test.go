@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCatalogMatch(t *testing.T) {
+	c := DefaultCatalog()
+	tests := []struct {
+		name string
+		ip   string
+		rdns string
+		dkim []string
+		want string
+	}{
+		{"cidr", "167.89.10.20", "", nil, "SendGrid"},
+		{"cidr v6", "2a00:1450:4000::1", "", nil, "Google Workspace"},
+		{"rdns", "192.0.2.1", "o1.ptr1234.sendgrid.net.", nil, "SendGrid"},
+		{"rdns case", "192.0.2.1", "A1-2.SMTP-OUT.AMAZONSES.COM", nil, "Amazon SES"},
+		{"rdns label-wise", "192.0.2.1", "mail.evilsendgrid.net", nil, ""},
+		{"dkim", "192.0.2.1", "", []string{"example.gappssmtp.com"}, "Google Workspace"},
+		{"dkim label-wise", "192.0.2.1", "", []string{"notamazonses.com"}, ""},
+		{"unknown", "192.0.2.1", "mail.example.com", []string{"example.com"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Match(net.ParseIP(tt.ip), tt.rdns, tt.dkim); got != tt.want {
+				t.Errorf("Match(%s, %q, %v) = %q, want %q", tt.ip, tt.rdns, tt.dkim, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogUserPrecedence(t *testing.T) {
+	user, err := ParseCatalog([]byte(`
+services:
+  - name: Our relay
+    rdns: [relay.example.com]
+    dkim: [example.com]
+  - name: Our SendGrid subuser
+    cidrs: [167.89.10.0/24]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := DefaultCatalog()
+	builtin := len(c.Services)
+	c.Extend(user)
+	if len(c.Services) != builtin+2 || len(user.Services) != 2 {
+		t.Fatalf("Extend: %d services (user %d)", len(c.Services), len(user.Services))
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		rdns string
+		dkim []string
+		want string
+	}{
+		// a user rdns or dkim rule beats a built-in CIDR
+		{"rdns over builtin cidr", "167.89.64.1", "relay.example.com", nil, "Our relay"},
+		{"dkim over builtin cidr", "209.85.128.1", "", []string{"example.com"}, "Our relay"},
+		{"cidr over builtin cidr", "167.89.10.20", "", nil, "Our SendGrid subuser"},
+		{"builtin", "167.89.64.1", "", nil, "SendGrid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Match(net.ParseIP(tt.ip), tt.rdns, tt.dkim); got != tt.want {
+				t.Errorf("Match(%s, %q, %v) = %q, want %q", tt.ip, tt.rdns, tt.dkim, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCatalogErrors(t *testing.T) {
+	for _, data := range []string{
+		"services:\n  - cidrs: [192.0.2.0/24]\n",
+		"services:\n  - name: x\n    cidrs: [192.0.2.0/33]\n",
+		"services: [",
+	} {
+		if _, err := ParseCatalog([]byte(data)); err == nil {
+			t.Errorf("ParseCatalog(%q): no error", data)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// bucket aggregates the results of a day (or a week)
+type bucket struct {
+	start, end time.Time
+	stats
+}
+
+// failRate returns the DMARC fail rate of the bucket (NaN without data)
+func (b *bucket) failRate() float64 {
+	if b.rated() == 0 {
+		return math.NaN()
+	}
+	return 1 - rate(b.DMARCPass, b.rated())
+}
+
+// truncateDay returns the day (or the monday of the week) of a date
+func truncateDay(t time.Time, weekly bool) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if weekly {
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return t
+}
+
+// timeSeries aggregates the results per day (or week) of the beginning of
+// their report, from the first to the last period, the periods without
+// report being empty
+func timeSeries(results FeedbackResults, weekly bool) []*bucket {
+	return timeSeriesOver(results, results, weekly)
+}
+
+// timeSeriesOver is timeSeries over the periods spanned by all
+func timeSeriesOver(results, all FeedbackResults, weekly bool) []*bucket {
+	if len(all) == 0 {
+		return nil
+	}
+	var s seen
+	for _, r := range all {
+		s.Add(r)
+	}
+	return timeSeriesBetween(results, s.first, s.last, weekly)
+}
+
+// timeSeriesBetween is timeSeries over the periods from first to last
+func timeSeriesBetween(results FeedbackResults, first, last time.Time, weekly bool) []*bucket {
+	step := 1
+	if weekly {
+		step = 7
+	}
+	index := make(map[time.Time]*bucket)
+	out := make([]*bucket, 0)
+	last = truncateDay(last, weekly)
+	for day := truncateDay(first, weekly); !day.After(last); day = day.AddDate(0, 0, step) {
+		b := &bucket{start: day, end: day.AddDate(0, 0, step)}
+		index[day] = b
+		out = append(out, b)
+	}
+	for _, r := range results {
+		if b := index[truncateDay(time.Time(r.Begin), weekly)]; b != nil {
+			b.Add(r)
+		}
+	}
+	return out
+}
+
+// query returns the filter selecting the records of the bucket
+func (b *bucket) query() string {
+	if b.end.Sub(b.start) <= 24*time.Hour {
+		return "begin=" + b.start.Format(shortDateFormat)
+	}
+	return "begin>=" + b.start.Format(shortDateFormat) + " begin<" + b.end.Format(shortDateFormat)
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the values with one block per value, relatively to the
+// highest one (NaN values are left blank)
+func sparkline(values []float64, max float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		switch {
+		case math.IsNaN(v):
+			b.WriteRune(' ')
+		case max <= 0:
+			b.WriteRune(sparks[0])
+		default:
+			i := int(math.Round(v / max * float64(len(sparks)-1)))
+			if i >= len(sparks) {
+				i = len(sparks) - 1
+			}
+			b.WriteRune(sparks[i])
+		}
+	}
+	return b.String()
+}
+
+// barChart draws the values as vertical bars of width columns, the top of
+// the bars in eighths of a line
+func barChart(values []float64, max float64, width, height int) []string {
+	lines := make([]string, height)
+	for row := 0; row < height; row++ {
+		var b strings.Builder
+		for _, v := range values {
+			c := ' '
+			if max > 0 && !math.IsNaN(v) {
+				level := int(math.Round(v / max * float64(height*8)))
+				floor := (height - row - 1) * 8
+				switch {
+				case level >= floor+8:
+					c = '█'
+				case level > floor:
+					c = sparks[level-floor-1]
+				}
+			}
+			b.WriteString(strings.Repeat(string(c), width))
+		}
+		lines[row] = b.String()
+	}
+	return lines
+}
+
+// braille dots of a character, by column and row
+var brailleDots = [2][4]rune{{0x01, 0x02, 0x04, 0x40}, {0x08, 0x10, 0x20, 0x80}}
+
+// lineChart draws the values (between 0 and max) as a line of braille
+// dots, every value spanning width columns, interpolated with the next one
+func lineChart(values []float64, max float64, width, height int) []string {
+	rows := height * 4
+	cells := make([][]rune, height)
+	for i := range cells {
+		cells[i] = make([]rune, len(values)*width)
+	}
+	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		next := v
+		if i+1 < len(values) && !math.IsNaN(values[i+1]) {
+			next = values[i+1]
+		}
+		// two dots per column
+		for d := 0; d < 2*width; d++ {
+			x := v + (next-v)*float64(d)/float64(2*width)
+			y := int(math.Round(math.Min(x/max, 1) * float64(rows-1)))
+			dot := rows - 1 - y
+			col := i*width + d/2
+			cells[dot/4][col] |= brailleDots[d%2][dot%4]
+		}
+	}
+	lines := make([]string, height)
+	for i, row := range cells {
+		var b strings.Builder
+		for _, c := range row {
+			b.WriteRune(0x2800 + c)
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+// chart is a view plotting the message volume and the DMARC fail rate
+// over time, a bucket (day or week) being selected
+type chart struct {
+	results FeedbackResults
+	buckets []*bucket
+	weekly  bool
+	cursor  int
+	width   int
+	height  int
+}
+
+func NewChart() *chart {
+	return &chart{width: 80, height: 10, cursor: -1}
+}
+
+func (c *chart) Name() string {
+	return "charts"
+}
+
+func (c *chart) Fetch(results FeedbackResults) tea.Cmd {
+	return nil
+}
+
+func (c *chart) SetSize(width, height int) {
+	c.width = width
+	c.height = height
+}
+
+func (c *chart) SetResults(results FeedbackResults) {
+	c.results = results
+	c.buckets = timeSeries(results, c.weekly)
+	// the most recent period by default
+	if c.cursor < 0 || c.cursor >= len(c.buckets) {
+		c.cursor = len(c.buckets) - 1
+	}
+}
+
+// columns returns the width of a bucket and the buckets displayed (the
+// most recent ones when they do not fit)
+func (c *chart) columns() (int, []*bucket, int) {
+	plot := c.width - 12
+	if plot < 10 {
+		plot = 10
+	}
+	buckets, offset := c.buckets, 0
+	if len(buckets) > plot {
+		offset = len(buckets) - plot
+		if c.cursor < offset {
+			offset = c.cursor
+		}
+		buckets = buckets[offset : offset+plot]
+	}
+	width := 1
+	if len(buckets) > 0 {
+		width = plot / len(buckets)
+	}
+	if width > 6 {
+		width = 6
+	}
+	if width < 1 {
+		width = 1
+	}
+	return width, buckets, offset
+}
+
+func (c *chart) Update(msg tea.Msg) (view, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "left", "h":
+			if c.cursor > 0 {
+				c.cursor--
+			}
+		case "right", "l":
+			if c.cursor < len(c.buckets)-1 {
+				c.cursor++
+			}
+		case "w":
+			c.weekly = !c.weekly
+			c.cursor = -1
+			c.SetResults(c.results)
+		case "enter":
+			if c.cursor >= 0 && c.cursor < len(c.buckets) {
+				query := c.buckets[c.cursor].query()
+				return c, func() tea.Msg { return DrillDownMsg(query) }
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *chart) View() string {
+	if len(c.buckets) == 0 {
+		return baseStyle.Render("no records")
+	}
+	width, buckets, offset := c.columns()
+	volumes := make([]float64, len(buckets))
+	fails := make([]float64, len(buckets))
+	max := 0.0
+	for i, b := range buckets {
+		volumes[i] = float64(b.Messages)
+		fails[i] = b.failRate()
+		max = math.Max(max, volumes[i])
+	}
+	height := (c.height - 5) / 2
+	if height < 2 {
+		height = 2
+	}
+
+	axis := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	primary := lipgloss.NewStyle().Foreground(Theme().primary)
+	title := lipgloss.NewStyle().Bold(true)
+	plot := func(lines []string, top, bottom string) string {
+		out := make([]string, len(lines))
+		for i, l := range lines {
+			label := ""
+			switch i {
+			case 0:
+				label = top
+			case len(lines) - 1:
+				label = bottom
+			}
+			out[i] = axis.Render(fmt.Sprintf("%8s │", label)) + primary.Render(l)
+		}
+		return strings.Join(out, "\n")
+	}
+
+	// selected bucket and dates below the charts
+	selected := c.buckets[c.cursor]
+	marker := strings.Repeat(" ", (c.cursor-offset)*width) + strings.Repeat("▲", width)
+	first := buckets[0].start.Format(shortDateFormat)
+	last := buckets[len(buckets)-1].start.Format(shortDateFormat)
+	dates := first
+	if pad := len(buckets)*width - len(first) - len(last); pad > 0 {
+		dates += strings.Repeat(" ", pad) + last
+	}
+	period := "day"
+	if c.weekly {
+		period = "week"
+	}
+
+	var b strings.Builder
+	b.WriteString(title.Render(" Messages per "+period) + "\n")
+	b.WriteString(plot(barChart(volumes, max, width, height), fmt.Sprint(int(max)), "0") + "\n")
+	b.WriteString(title.Render(" DMARC fail rate") + "\n")
+	b.WriteString(plot(lineChart(fails, 1, width, height), "100%", "0%") + "\n")
+	b.WriteString(strings.Repeat(" ", 10) + primary.Render(marker) + "\n")
+	b.WriteString(strings.Repeat(" ", 10) + axis.Render(dates) + "\n")
+	fail := "-"
+	if !math.IsNaN(selected.failRate()) {
+		fail = fmt.Sprintf("%.1f%%", 100*selected.failRate())
+	}
+	fmt.Fprintf(&b, " %s: %d messages, DMARC fail rate %s, %d transient (←/→ select, w daily/weekly, enter show records)",
+		selected.query(), selected.Messages, fail, selected.Transient)
+	return b.String()
+}
+
+// sparklineDays is the longest period drawn day by day by trendDetail
+const sparklineDays = 60
+
+// trendDetail draws the volume and DMARC fail rate of the records of the
+// selected row per day (per week over long periods), over the period of
+// all the results
+func trendDetail(all, selected FeedbackResults) string {
+	buckets := timeSeriesOver(selected, all, false)
+	if len(buckets) > sparklineDays {
+		buckets = timeSeriesOver(selected, all, true)
+	}
+	volumes := make([]float64, len(buckets))
+	fails := make([]float64, len(buckets))
+	max := 0.0
+	for i, b := range buckets {
+		volumes[i] = math.NaN()
+		if b.Messages > 0 {
+			volumes[i] = float64(b.Messages)
+		}
+		fails[i] = b.failRate()
+		max = math.Max(max, float64(b.Messages))
+	}
+	return fmt.Sprintf(" volume %s  DMARC fail rate %s",
+		sparkline(volumes, max), sparkline(fails, 1))
+}
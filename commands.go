@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// command is a non-interactive command, run on the records of the
+// directory and the query
+type command struct {
+	name  string
+	usage string
+	// run prints its output and returns the exit status
+	run func(results FeedbackResults, reports []*dmarc.Report, f *Filter) int
+}
+
+var commands = []command{
+	{
+		name:  "alerts",
+		usage: "print the anomalies of the daily volumes matching the query (exit status 2 if any)",
+		run:   alertsCommand,
+	},
+	{
+		name:  "spoofing",
+		usage: "print the spoofed records as an evidence list (CSV)",
+		run:   spoofingCommand,
+	},
+}
+
+// runCommand runs the command of the arguments, the next arguments being
+// the filter query
+func runCommand(args []string) int {
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		f, err := ParseFilter(strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid query: %v\n", err)
+			return 1
+		}
+		results, reports := search(directory)
+		indexFirstSeen(results)
+		return c.run(results, reports, f)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %s\n", args[0])
+	return 1
+}
+
+// printJSON writes v as indented JSON on the standard output
+func printJSON(v interface{}) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// alertsCommand detects the anomalies on the whole history, then prints the
+// ones since the -since day whose records match the query
+func alertsCommand(results FeedbackResults, reports []*dmarc.Report, f *Filter) int {
+	var since time.Time
+	if alertsSince != "" {
+		var err error
+		if since, err = time.Parse(shortDateFormat, alertsSince); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid day %s: %v\n", alertsSince, err)
+			return 1
+		}
+	}
+	alerts := make([]*Alert, 0)
+	for _, a := range DetectAnomalies(results, reportsWithoutRecords(reports, nil), alertScore) {
+		if a.Day.Before(since) || !a.matches(results, f) {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	if jsonOutput {
+		if status := printJSON(alerts); status != 0 {
+			return status
+		}
+	} else {
+		for _, a := range alerts {
+			fmt.Println(a)
+		}
+	}
+	if len(alerts) > 0 {
+		return 2
+	}
+	return 0
+}
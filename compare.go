@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// lengths of the compared windows, in days
+var compareWindows = []int{1, 7, 14, 30}
+
+// contains tells whether the report of the result begins in the bucket
+func (b *bucket) contains(r *FeedbackResult) bool {
+	day := truncateDay(time.Time(r.Begin), false)
+	return !day.Before(b.start) && day.Before(b.end)
+}
+
+func (b *bucket) String() string {
+	return b.start.Format(shortDateFormat) + " → " + b.end.AddDate(0, 0, -1).Format(shortDateFormat)
+}
+
+// comparison is a view comparing two consecutive windows (the last week
+// and the week before by default), per domain or per sender
+type comparison struct {
+	*summary
+	// index of the window length
+	window int
+	// windows between the last one and the compared one
+	shift    int
+	bySender bool
+}
+
+func NewComparison() *comparison {
+	c := &comparison{window: 1}
+	c.summary = NewSummary("compare", c.build)
+	return c
+}
+
+// windows returns the compared window and the one before it, the last one
+// ending with the last report of the results
+func (c *comparison) windows(results FeedbackResults) (*bucket, *bucket) {
+	days := compareWindows[c.window]
+	// the day after the last report begins
+	var end time.Time
+	for _, r := range results {
+		if day := truncateDay(time.Time(r.Begin), false).AddDate(0, 0, 1); day.After(end) {
+			end = day
+		}
+	}
+	end = end.AddDate(0, 0, -c.shift*days)
+	current := &bucket{start: end.AddDate(0, 0, -days), end: end}
+	previous := &bucket{start: current.start.AddDate(0, 0, -days), end: current.start}
+	return current, previous
+}
+
+// delta compares two rates in percentage points
+func delta(before, after *stats, pass func(*stats) int) string {
+	if before.rated() == 0 || after.rated() == 0 {
+		return "-"
+	}
+	d := 100 * (rate(pass(after), after.rated()) - rate(pass(before), before.rated()))
+	return fmt.Sprintf("%+.1f pp", d)
+}
+
+// comparedGroup is a domain or sender in both windows
+type comparedGroup struct {
+	key               string
+	previous, current stats
+	// results of the previous window, to tell whether a sender that
+	// disappeared was legitimate
+	before FeedbackResults
+}
+
+func (g *comparedGroup) status() string {
+	switch {
+	case g.previous.Messages == 0:
+		return "appeared"
+	case g.current.Messages == 0 && legitimateSender(g.before):
+		return "disappeared (legitimate)"
+	case g.current.Messages == 0:
+		return "disappeared"
+	}
+	return ""
+}
+
+// build compares the windows per header_from domain or per sender, the
+// senders that disappeared first, then the ones that appeared
+func (c *comparison) build(results FeedbackResults) ([]string, []summaryRow) {
+	current, previous := c.windows(results)
+	field, key := "header_from", func(r *FeedbackResult) string { return r.HeaderFrom }
+	if c.bySender {
+		field, key = "sender", func(r *FeedbackResult) string { return r.Sender() }
+	}
+	index := make(map[string]*comparedGroup)
+	for _, r := range results {
+		in, before := current.contains(r), previous.contains(r)
+		if !in && !before {
+			continue
+		}
+		g := index[key(r)]
+		if g == nil {
+			g = &comparedGroup{key: key(r)}
+			index[key(r)] = g
+		}
+		if in {
+			g.current.Add(r)
+		} else {
+			g.previous.Add(r)
+			g.before = append(g.before, r)
+		}
+	}
+	groups := make([]*comparedGroup, 0, len(index))
+	for _, g := range index {
+		groups = append(groups, g)
+	}
+	rank := map[string]int{"disappeared (legitimate)": 0, "disappeared": 1, "appeared": 2, "": 3}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if rank[a.status()] != rank[b.status()] {
+			return rank[a.status()] < rank[b.status()]
+		}
+		if va, vb := a.current.Messages+a.previous.Messages, b.current.Messages+b.previous.Messages; va != vb {
+			return va > vb
+		}
+		return a.key < b.key
+	})
+
+	dmarcPass := func(s *stats) int { return s.DMARCPass }
+	dkimPass := func(s *stats) int { return s.DKIMPass }
+	spfPass := func(s *stats) int { return s.SPFPass }
+	rows := make([]summaryRow, 0, len(groups))
+	for _, g := range groups {
+		name := g.key
+		if !c.bySender {
+			name = dmarc.DisplayDomain(g.key)
+		}
+		window := current
+		if g.current.Messages == 0 {
+			window = previous
+		}
+		change := "-"
+		if g.previous.Messages > 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*(rate(g.current.Messages, g.previous.Messages)-1))
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			name,
+			g.status(),
+			strconv.Itoa(g.previous.Messages),
+			strconv.Itoa(g.current.Messages),
+			fmt.Sprintf("%+d", g.current.Messages-g.previous.Messages),
+			change,
+			g.previous.DMARCRate(),
+			g.current.DMARCRate(),
+			delta(&g.previous, &g.current, dmarcPass),
+			delta(&g.previous, &g.current, dkimPass),
+			delta(&g.previous, &g.current, spfPass),
+		}, query: queryTerm(field, g.key) + " " + window.query()})
+	}
+	return []string{field, "status", "before", "after", "Δ messages", "Δ %", "dmarc before", "dmarc after", "Δ dmarc", "Δ dkim", "Δ spf"}, rows
+}
+
+func (c *comparison) SetSize(width, height int) {
+	// room for the windows
+	c.summary.SetSize(width, height-1)
+}
+
+func (c *comparison) Update(msg tea.Msg) (view, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "w":
+			c.window = (c.window + 1) % len(compareWindows)
+			c.shift = 0
+		case "[":
+			c.shift++
+		case "]":
+			if c.shift > 0 {
+				c.shift--
+			}
+		case "p":
+			c.bySender = !c.bySender
+		default:
+			_, cmd := c.summary.Update(msg)
+			return c, cmd
+		}
+		c.SetResults(c.results)
+	}
+	return c, nil
+}
+
+func (c *comparison) View() string {
+	current, previous := c.windows(c.results)
+	by := "domain"
+	if c.bySender {
+		by = "sender"
+	}
+	header := lipgloss.NewStyle().Foreground(Theme().primary).Render(
+		fmt.Sprintf(" %s vs %s, per %s", current, previous, by))
+	help := lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(
+		"  (w window, [/] earlier/later, p per domain/sender)")
+	return header + help + "\n" + c.summary.View()
+}
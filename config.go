@@ -3,3 +3,18 @@ package main
 var directory = "."
 var selectedTheme = "default"
 var highlightXML = false
+var catalogFile = ""
+var dnsServer = ""
+var offline = false
+var dnsblZones = ""
+var rhsblZones = ""
+var asnFile = ""
+var recordColumns = ""
+var suffixFile = ""
+var replaceSuffixes = false
+var readinessWindow = 30
+var meaningfulShare = 1.0
+var newWindow = 7
+var alertScore = 4.0
+var alertsSince = ""
+var jsonOutput = false
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// number of entries of the top lists of the dashboard
+const dashboardTop = 5
+
+// width of the content of a tile
+const tileWidth = 36
+
+// tileLine is a line of a tile, the query selecting its records
+type tileLine struct {
+	label string
+	value string
+	query string
+}
+
+// tile is a box of the dashboard
+type tile struct {
+	title string
+	lines []tileLine
+}
+
+// dashboard is the landing view: the big picture of the selected records,
+// every line of its tiles leading to the records behind it
+type dashboard struct {
+	tiles []tile
+	// reports without record matching the filter
+	empty  []*dmarc.Report
+	cursor int
+	height int
+}
+
+func NewDashboard() *dashboard {
+	return &dashboard{height: 10}
+}
+
+func (d *dashboard) Name() string {
+	return "dashboard"
+}
+
+func (d *dashboard) Fetch(results FeedbackResults) tea.Cmd {
+	return nil
+}
+
+func (d *dashboard) SetSize(width, height int) {
+	d.height = height
+}
+
+func (d *dashboard) SetReports(empty []*dmarc.Report) {
+	d.empty = empty
+}
+
+func (d *dashboard) SetResults(results FeedbackResults) {
+	d.tiles = dashboardTiles(results, d.empty)
+	if n := len(d.lines()); d.cursor >= n {
+		d.cursor = n - 1
+	}
+	if d.cursor < 0 {
+		d.cursor = 0
+	}
+}
+
+// lines returns the lines of the tiles, in order
+func (d *dashboard) lines() []tileLine {
+	out := make([]tileLine, 0)
+	for _, t := range d.tiles {
+		out = append(out, t.lines...)
+	}
+	return out
+}
+
+func (d *dashboard) Update(msg tea.Msg) (view, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		lines := d.lines()
+		switch msg.String() {
+		case "up", "k":
+			if d.cursor > 0 {
+				d.cursor--
+			}
+		case "down", "j":
+			if d.cursor < len(lines)-1 {
+				d.cursor++
+			}
+		case "home", "g":
+			d.cursor = 0
+		case "end", "G":
+			d.cursor = len(lines) - 1
+		case "enter":
+			if d.cursor < len(lines) {
+				query := lines[d.cursor].query
+				return d, func() tea.Msg { return DrillDownMsg(query) }
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *dashboard) View() string {
+	selected := tableStyle().Selected
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(Theme().primary)
+	boxStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1)
+
+	boxes := make([]string, 0, len(d.tiles))
+	i := 0
+	for _, t := range d.tiles {
+		content := titleStyle.Render(t.title)
+		for _, l := range t.lines {
+			line := tileText(l)
+			if i == d.cursor {
+				line = selected.Render(line)
+			}
+			content += "\n" + line
+			i++
+		}
+		boxes = append(boxes, boxStyle.Render(content))
+	}
+	// three tiles per row
+	rows := make([]string, 0)
+	for k := 0; k < len(boxes); k += 3 {
+		end := k + 3
+		if end > len(boxes) {
+			end = len(boxes)
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, boxes[k:end]...))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// tileText lays out a line of a tile, the value on the right
+func tileText(l tileLine) string {
+	value := runewidth.Truncate(l.value, tileWidth-12, "…")
+	label := runewidth.Truncate(l.label, tileWidth-runewidth.StringWidth(value)-1, "…")
+	pad := tileWidth - runewidth.StringWidth(label) - runewidth.StringWidth(value)
+	return label + strings.Repeat(" ", pad) + value
+}
+
+// dashboardTiles builds the tiles from the results and the reports without
+// record
+func dashboardTiles(results FeedbackResults, empty []*dmarc.Report) []tile {
+	var total stats
+	dispositions := make(map[string]int)
+	failing := make(map[string]int)
+	sources := make(map[string]string)
+	domains := make(map[string]int)
+	reporters := make(map[string]int)
+	newSenders := make(map[string]*stats)
+	indirect := make(map[string]*stats)
+	forwardedFailing, spoofed := 0, 0
+	var period seen
+	for _, r := range results {
+		total.Add(r)
+		dispositions[r.Disposition] += r.Count
+		if !r.Pass() {
+			ip := r.SourceIP.String()
+			failing[ip] += r.Count
+			sources[ip] = r.Source
+		}
+		domains[r.HeaderFrom] += r.Count
+		reporters[r.OrgName] += r.Count
+		if r.NewSender() {
+			if newSenders[r.Sender()] == nil {
+				newSenders[r.Sender()] = &stats{}
+			}
+			newSenders[r.Sender()].Add(r)
+		}
+		if kind, _ := r.Forwarding(); kind != "" {
+			if indirect[kind] == nil {
+				indirect[kind] = &stats{}
+			}
+			indirect[kind].Add(r)
+			if !r.Pass() {
+				forwardedFailing += r.Count
+			}
+		}
+		if r.Spoofed() {
+			spoofed += r.Count
+		}
+		period.Add(r)
+	}
+
+	days := "-"
+	if !period.first.IsZero() {
+		days = period.first.Format(shortDateFormat) + " → " + period.last.Format(shortDateFormat)
+	}
+	overview := tile{title: "Overview", lines: []tileLine{
+		{label: "period", value: days},
+		{label: "messages", value: strconv.Itoa(total.Messages)},
+		{label: "records", value: strconv.Itoa(total.Records)},
+		{label: "reports", value: strconv.Itoa(len(results.Reports(empty)))},
+		{label: "reporters", value: strconv.Itoa(len(reporters))},
+	}}
+
+	failed := total.Messages - total.DMARCPass - total.Transient
+	rates := tile{title: "Pass rates", lines: []tileLine{
+		{label: "DMARC", value: total.DMARCRate(), query: "dmarc=fail"},
+		{label: "DKIM", value: total.DKIMRate(), query: "dkim!=pass"},
+		{label: "SPF", value: total.SPFRate(), query: "spf!=pass"},
+		{label: "failing messages", value: strconv.Itoa(failed), query: "dmarc=fail failure=permanent"},
+		{label: "transient errors", value: strconv.Itoa(total.Transient), query: "failure=transient"},
+	}}
+
+	disp := tile{title: "Dispositions"}
+	for _, k := range topKeys(dispositions) {
+		label := k
+		if label == "" {
+			label = "-"
+		}
+		disp.lines = append(disp.lines, tileLine{
+			label: label,
+			value: fmt.Sprintf("%d (%s)", dispositions[k], percent(dispositions[k], total.Messages)),
+			query: queryTerm("disposition", k),
+		})
+	}
+
+	top := tile{title: "Top failing sources"}
+	for _, ip := range firstKeys(failing, dashboardTop) {
+		label := ip
+		if s := sources[ip]; s != "" && s != ip {
+			label = s
+		}
+		top.lines = append(top.lines, tileLine{
+			label: label,
+			value: strconv.Itoa(failing[ip]),
+			query: queryTerm("source_ip", ip) + " dmarc=fail",
+		})
+	}
+
+	from := tile{title: "Top domains"}
+	for _, d := range firstKeys(domains, dashboardTop) {
+		from.lines = append(from.lines, tileLine{
+			label: domainLabel(d),
+			value: strconv.Itoa(domains[d]),
+			query: queryTerm("header_from", d),
+		})
+	}
+
+	// the reporters whose reports stopped first
+	stopped := make(map[string]bool)
+	for _, c := range reporterCoverages(results, empty) {
+		stopped[c.Name] = c.Stopped()
+	}
+	names := firstKeys(reporters, len(reporters))
+	sort.SliceStable(names, func(i, j int) bool { return stopped[names[i]] && !stopped[names[j]] })
+	if len(names) > dashboardTop {
+		names = names[:dashboardTop]
+	}
+	rep := tile{title: "Reporters"}
+	for _, o := range names {
+		value := strconv.Itoa(reporters[o])
+		if stopped[o] {
+			value += " (stopped)"
+		}
+		rep.lines = append(rep.lines, tileLine{
+			label: o,
+			value: value,
+			query: queryTerm("reporter", o),
+		})
+	}
+
+	volumes := make(map[string]int)
+	for s, st := range newSenders {
+		volumes[s] = st.Messages
+	}
+	fresh := tile{title: fmt.Sprintf("New senders (%d)", len(newSenders))}
+	for _, s := range firstKeys(volumes, dashboardTop) {
+		fresh.lines = append(fresh.lines, tileLine{
+			label: s,
+			value: fmt.Sprintf("%d, %s pass", volumes[s], newSenders[s].DMARCRate()),
+			query: queryTerm("sender", s) + " new=yes",
+		})
+	}
+	if len(newSenders) == 0 {
+		fresh.lines = append(fresh.lines, tileLine{label: "none", query: "new=yes"})
+	}
+
+	alerts := DetectAnomalies(results, empty, alertScore)
+	anomalies := tile{title: fmt.Sprintf("Alerts (%d)", len(alerts))}
+	for i, a := range alerts {
+		if i == dashboardTop {
+			break
+		}
+		anomalies.lines = append(anomalies.lines, tileLine{
+			label: a.Day.Format("01-02") + " " + a.Key,
+			value: fmt.Sprintf("%d %s", a.Value, a.Metric),
+			query: a.query(),
+		})
+	}
+	if len(alerts) == 0 {
+		anomalies.lines = append(anomalies.lines, tileLine{label: "none"})
+	}
+
+	forwarding := tile{title: "Forwarding"}
+	for _, kind := range []string{forwardedTraffic, mailingListTraffic} {
+		s := indirect[kind]
+		if s == nil {
+			s = &stats{}
+		}
+		forwarding.lines = append(forwarding.lines, tileLine{
+			label: strings.ReplaceAll(kind, "_", " "),
+			value: fmt.Sprintf("%d, %s pass", s.Messages, s.DMARCRate()),
+			query: "forwarded=" + kind,
+		})
+	}
+	forwarding.lines = append(forwarding.lines,
+		tileLine{label: "failing DMARC", value: strconv.Itoa(forwardedFailing), query: "forwarded=yes dmarc=fail"},
+		tileLine{label: "spoofed", value: strconv.Itoa(spoofed), query: "spoofed=yes"},
+	)
+
+	return []tile{overview, rates, disp, top, from, rep, fresh, anomalies, forwarding}
+}
+
+// firstKeys returns the n keys with the highest counts
+func firstKeys(m map[string]int, n int) []string {
+	keys := topKeys(m)
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
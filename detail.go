@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// SPFCheckMsg carries the result of a live SPF evaluation
+type SPFCheckMsg struct {
+	key    string
+	result *SPFResult
+}
+
+// spfKey identifies an SPF evaluation (domain and source IP)
+func spfKey(r *FeedbackResult) string {
+	return normalizeName(r.SPFDomain()) + "|" + r.SourceIP.String()
+}
+
+// checkSPF evaluates SPF for the record in background
+func checkSPF(r *FeedbackResult) tea.Cmd {
+	key := spfKey(r)
+	ip := r.SourceIP
+	domain := r.SPFDomain()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		defer cancel()
+		return SPFCheckMsg{key: key, result: CheckSPF(ctx, resolver, ip, domain)}
+	}
+}
+
+// recordDetail is the content of the detail pane: what tmarc knows about
+// the record followed by its raw XML
+func recordDetail(r *FeedbackResult, spf *SPFResult) string {
+	var b strings.Builder
+	b.WriteString(reportDetail(r.Report))
+	b.WriteString(newDetail(r))
+	b.WriteString(forwardingDetail(r))
+	fmt.Fprintf(&b, "Policy of %s (as seen by %s): %s\n", domainLabel(r.Policy.Domain), r.OrgName, r.Policy)
+	fmt.Fprintf(&b, "Disposition: %s (expected %s)\n", r.Disposition, r.ExpectedDisposition())
+	for _, reason := range r.Reasons {
+		fmt.Fprintf(&b, "  override: %s", reason.Type)
+		if reason.Comment != "" {
+			fmt.Fprintf(&b, " (%s)", reason.Comment)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(authDetail(r))
+	b.WriteString(spfDetail(r, spf))
+	b.WriteString("\n")
+	b.Write(r.XML)
+	return b.String()
+}
+
+// authDetail lists the signatures and checks reported by the receiver
+func authDetail(r *FeedbackResult) string {
+	var b strings.Builder
+	b.WriteString("Authentication results (reported):\n")
+	if len(r.DKIMAuth) == 0 && len(r.SPFAuth) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, d := range r.DKIMAuth {
+		fmt.Fprintf(&b, "  DKIM %s (s=%s): %s", domainLabel(d.Domain), d.Selector, resultLabel(d.Result, d.RawResult))
+		if d.HumanResult != "" {
+			fmt.Fprintf(&b, " (%s)", d.HumanResult)
+		}
+		b.WriteString("\n")
+	}
+	for _, s := range r.SPFAuth {
+		fmt.Fprintf(&b, "  SPF %s: %s\n", domainLabel(s.Domain), resultLabel(s.Result, s.RawResult))
+	}
+	n := r.Policy.Normalized()
+	fmt.Fprintf(&b, "Alignment with %s: dkim %s (adkim=%s), spf %s (aspf=%s)\n",
+		domainLabel(r.FromDomain()), r.DKIMAlignment(), n.ADKIM, r.SPFAlignment(), n.ASPF)
+	return b.String()
+}
+
+// resultLabel shows a result with its class, and the raw value when it
+// differs
+func resultLabel(result dmarc.Result, raw string) string {
+	label := fmt.Sprintf("%s [%s]", result, result.Class())
+	if !strings.EqualFold(raw, string(result)) {
+		label += fmt.Sprintf(" (reported as %q)", raw)
+	}
+	return label
+}
+
+func spfDetail(r *FeedbackResult, spf *SPFResult) string {
+	if r.SPFDomain() == "" {
+		return "SPF (live): no SPF domain in the report\n"
+	}
+	if offline {
+		return "SPF (live): disabled in offline mode\n"
+	}
+	if spf == nil {
+		return fmt.Sprintf("SPF (live) for %s: checking…\n", r.SPFDomain())
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SPF (live) for %s: %s\n", spf.Domain, spf.Result)
+	if spf.Authorized() {
+		fmt.Fprintf(&b, "  %s is authorized\n", spf.IP)
+	} else {
+		fmt.Fprintf(&b, "  %s is not authorized\n", spf.IP)
+	}
+	if spf.Mechanism != "" {
+		fmt.Fprintf(&b, "  matched: %s\n", strings.Join(spf.Path, " > "))
+	}
+	if spf.Reason != "" {
+		fmt.Fprintf(&b, "  reason: %s\n", spf.Reason)
+	}
+	fmt.Fprintf(&b, "  dns lookups: %d/%d (void: %d/%d)\n",
+		spf.Lookups, spfLookupLimit, spf.VoidLookups, spfVoidLimit)
+	if r.SPFResult != "" {
+		fmt.Fprintf(&b, "  policy_evaluated: %s\n", r.SPFResult)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// overrideStats aggregates the overridden messages of a reason
+type overrideStats struct {
+	Reason       string
+	Records      int
+	Messages     int
+	Dispositions map[string]int
+	Comments     map[string]int
+	// messages per reporter
+	Reporters map[string]int
+}
+
+// overridesSummary shows how often the receivers overrode the policy, per
+// reason, every reason expanding into its reporters
+func overridesSummary(results FeedbackResults) ([]string, []summaryRow) {
+	index := make(map[string]*overrideStats)
+	failing := 0
+	for _, r := range results {
+		if r.ExpectedDisposition() != "none" {
+			failing += r.Count
+		}
+		if !r.Overridden() {
+			continue
+		}
+		reasons := r.ReasonTypes()
+		if len(reasons) == 0 {
+			// more lenient disposition without explanation
+			reasons = []string{"-"}
+		}
+		for _, reason := range reasons {
+			s, exists := index[reason]
+			if !exists {
+				s = &overrideStats{
+					Reason:       reason,
+					Dispositions: make(map[string]int),
+					Comments:     make(map[string]int),
+					Reporters:    make(map[string]int),
+				}
+				index[reason] = s
+			}
+			s.Records++
+			s.Messages += r.Count
+			s.Dispositions[r.Disposition] += r.Count
+			s.Reporters[r.OrgName] += r.Count
+			for _, c := range r.ReasonComments() {
+				s.Comments[c] += r.Count
+			}
+		}
+	}
+	reasons := make([]*overrideStats, 0, len(index))
+	for _, s := range index {
+		reasons = append(reasons, s)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Messages == reasons[j].Messages {
+			return reasons[i].Reason < reasons[j].Reason
+		}
+		return reasons[i].Messages > reasons[j].Messages
+	})
+
+	rows := make([]summaryRow, 0, len(reasons))
+	for _, s := range reasons {
+		query := queryTerm("reason", s.Reason)
+		if s.Reason == "-" {
+			query = "overridden=yes reason=\"\""
+		}
+		children := make([]summaryRow, 0, len(s.Reporters))
+		for _, reporter := range topKeys(s.Reporters) {
+			children = append(children, summaryRow{
+				cells: []string{reporter, "", strconv.Itoa(s.Reporters[reporter]), "", "", ""},
+				query: query + " " + queryTerm("reporter", reporter),
+			})
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			s.Reason,
+			strconv.Itoa(s.Records),
+			strconv.Itoa(s.Messages),
+			percent(s.Messages, failing),
+			strings.Join(topKeys(s.Dispositions), ", "),
+			strings.Join(topKeys(s.Comments), ", "),
+		}, query: query, children: children})
+	}
+	return []string{"reason", "records", "messages", "of failing", "disposition", "comments"}, rows
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// DKIMKey describes the public key published at <selector>._domainkey.<domain>
+type DKIMKey struct {
+	// key type (rsa, ed25519)
+	Type string
+	// key length in bits
+	Bits int
+	// Revoked is true when the record has an empty p= tag
+	Revoked bool
+	// Missing is true when the selector does not exist in DNS
+	Missing bool
+	Err     error
+}
+
+// Status summarizes the health of the key
+func (k *DKIMKey) Status() string {
+	switch {
+	case k == nil:
+		return "…"
+	case k.Missing:
+		return "missing"
+	case k.Err != nil:
+		return "error"
+	case k.Revoked:
+		return "revoked"
+	case k.Type == "rsa" && k.Bits < 1024:
+		return "insecure"
+	case k.Type == "rsa" && k.Bits < 2048:
+		return "weak"
+	}
+	return "ok"
+}
+
+func (k *DKIMKey) String() string {
+	if k == nil || k.Missing || k.Revoked || k.Err != nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s-%d", k.Type, k.Bits)
+}
+
+// ParseDKIMKey parses a DKIM key record (RFC 6376 section 3.6.1)
+func ParseDKIMKey(record string) *DKIMKey {
+	key := &DKIMKey{Type: "rsa"}
+	data := ""
+	found := false
+	for _, tag := range strings.Split(record, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(tag), "=")
+		if !ok {
+			continue
+		}
+		// base64 may be split by whitespaces
+		value = strings.Join(strings.Fields(value), "")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "v":
+			if value != "DKIM1" {
+				key.Err = fmt.Errorf("invalid version %q", value)
+				return key
+			}
+		case "k":
+			key.Type = strings.ToLower(value)
+		case "p":
+			data = value
+			found = true
+		}
+	}
+	if !found {
+		key.Err = fmt.Errorf("missing p tag")
+		return key
+	}
+	if data == "" {
+		key.Revoked = true
+		return key
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		key.Err = err
+		return key
+	}
+	switch key.Type {
+	case "rsa":
+		pub, err := x509.ParsePKIXPublicKey(raw)
+		if err != nil {
+			// some signers publish a PKCS#1 key
+			if rsaKey, err1 := x509.ParsePKCS1PublicKey(raw); err1 == nil {
+				key.Bits = rsaKey.N.BitLen()
+				return key
+			}
+			key.Err = err
+			return key
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			key.Err = fmt.Errorf("not an rsa key")
+			return key
+		}
+		key.Bits = rsaKey.N.BitLen()
+	case "ed25519":
+		if len(raw) != ed25519.PublicKeySize {
+			key.Err = fmt.Errorf("invalid ed25519 key length")
+			return key
+		}
+		key.Bits = 8 * len(raw)
+	default:
+		key.Err = fmt.Errorf("unknown key type %q", key.Type)
+	}
+	return key
+}
+
+// LookupDKIMKey fetches the key of a selector
+func LookupDKIMKey(ctx context.Context, r Resolver, domain, selector string) *DKIMKey {
+	name := normalizeName(selector) + "._domainkey." + normalizeName(domain)
+	txts, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		if isNotFound(err) {
+			return &DKIMKey{Missing: true}
+		}
+		return &DKIMKey{Err: err}
+	}
+	if len(txts) == 0 {
+		return &DKIMKey{Missing: true}
+	}
+	// a TXT record may be split in several strings, but several keys
+	// would be a misconfiguration: only the first one is considered
+	return ParseDKIMKey(txts[0])
+}
+
+// dkimKeys caches the live keys per selector (see selectorKey)
+var dkimKeys = struct {
+	sync.Mutex
+	keys map[string]*DKIMKey
+}{keys: make(map[string]*DKIMKey)}
+
+func selectorKey(domain, selector string) string {
+	return normalizeName(selector) + "._domainkey." + normalizeName(domain)
+}
+
+func getDKIMKey(domain, selector string) *DKIMKey {
+	dkimKeys.Lock()
+	defer dkimKeys.Unlock()
+	return dkimKeys.keys[selectorKey(domain, selector)]
+}
+
+// fetchDKIMKeys looks up in background the keys of the selectors found in
+// the reports that are not in the cache yet
+func fetchDKIMKeys(results FeedbackResults) tea.Cmd {
+	if offline {
+		return nil
+	}
+	type selector struct{ domain, name string }
+	selectors := make([]selector, 0)
+	seen := make(map[string]bool)
+	for _, r := range results {
+		for _, d := range r.DKIMAuth {
+			k := selectorKey(d.Domain, d.Selector)
+			if d.Selector == "" || seen[k] || getDKIMKey(d.Domain, d.Selector) != nil {
+				continue
+			}
+			seen[k] = true
+			selectors = append(selectors, selector{d.Domain, d.Selector})
+		}
+	}
+	if len(selectors) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		var wg sync.WaitGroup
+		for _, s := range selectors {
+			wg.Add(1)
+			go func(domain, name string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+				defer cancel()
+				key := LookupDKIMKey(ctx, resolver, domain, name)
+				dkimKeys.Lock()
+				dkimKeys.keys[selectorKey(domain, name)] = key
+				dkimKeys.Unlock()
+			}(s.domain, s.name)
+		}
+		wg.Wait()
+		return RefreshViewMsg{}
+	}
+}
+
+// selectorStats is the inventory entry of a DKIM selector
+type selectorStats struct {
+	Domain   string
+	Selector string
+	Messages int
+	Pass     int
+	First    time.Time
+	Last     time.Time
+	Services map[string]int
+}
+
+// selectorInventory aggregates the DKIM signatures per (domain, selector)
+func selectorInventory(results FeedbackResults) []*selectorStats {
+	index := make(map[string]*selectorStats)
+	for _, r := range results {
+		for _, d := range r.DKIMAuth {
+			domain := normalizeName(d.Domain)
+			k := selectorKey(domain, d.Selector)
+			s, exists := index[k]
+			if !exists {
+				s = &selectorStats{
+					Domain:   domain,
+					Selector: d.Selector,
+					First:    time.Time(r.Begin),
+					Last:     time.Time(r.End),
+					Services: make(map[string]int),
+				}
+				index[k] = s
+			}
+			s.Messages += r.Count
+			if d.Result == dmarc.ResultPass {
+				s.Pass += r.Count
+			}
+			if begin := time.Time(r.Begin); begin.Before(s.First) {
+				s.First = begin
+			}
+			if end := time.Time(r.End); end.After(s.Last) {
+				s.Last = end
+			}
+			s.Services[r.ServiceName()] += r.Count
+		}
+	}
+	out := make([]*selectorStats, 0, len(index))
+	for _, s := range index {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Domain != out[j].Domain {
+			return out[i].Domain < out[j].Domain
+		}
+		return out[i].Messages > out[j].Messages
+	})
+	return out
+}
+
+// topKeys returns the keys of m sorted by descending value
+func topKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] == m[keys[j]] {
+			return keys[i] < keys[j]
+		}
+		return m[keys[i]] > m[keys[j]]
+	})
+	return keys
+}
+
+// selectorsSummary is the DKIM selector inventory with the key health
+func selectorsSummary(results FeedbackResults) ([]string, []summaryRow) {
+	inventory := selectorInventory(results)
+	rows := make([]summaryRow, 0, len(inventory))
+	for _, s := range inventory {
+		key := getDKIMKey(s.Domain, s.Selector)
+		status := key.Status()
+		if offline {
+			status = "-"
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			dmarc.DisplayDomain(s.Domain),
+			s.Selector,
+			strconv.Itoa(s.Messages),
+			percent(s.Pass, s.Messages),
+			s.First.Format(shortDateFormat),
+			s.Last.Format(shortDateFormat),
+			strings.Join(topKeys(s.Services), ", "),
+			key.String(),
+			status,
+		}, query: "dkim_auth[" + queryTerm("domain", s.Domain) + "," + queryTerm("selector", s.Selector) + "]"})
+	}
+	return []string{
+		"domain", "selector", "messages", "pass", "first", "last", "services", "key", "dns",
+	}, rows
+}
@@ -0,0 +1,101 @@
+package dmarc
+
+// alignment classes of an authentication mechanism
+const (
+	// the mechanism passed for a domain aligned with the header_from
+	AlignedPass = "aligned"
+	// the mechanism passed but only for unaligned domains
+	UnalignedPass = "unaligned"
+	// the mechanism did not pass at all
+	AuthFail = "fail"
+)
+
+// OrgDomain returns the organizational domain of a name according to the
+// Public Suffix List (see Suffixes)
+func OrgDomain(name string) string {
+	return Suffixes.OrgDomain(name)
+}
+
+// Aligned tells whether an authenticated domain is aligned with the
+// header_from (RFC 7489 section 3.1), mode being "s" (strict) or "r"
+// (relaxed)
+func Aligned(domain, from, mode string) bool {
+	domain, from = normalizeName(domain), normalizeName(from)
+	if domain == "" || from == "" {
+		return false
+	}
+	if mode == "s" {
+		return domain == from
+	}
+	return OrgDomain(domain) == OrgDomain(from)
+}
+
+// FromDomain returns the domain the alignment is checked against
+func (r *Record) FromDomain() string {
+	if r.HeaderFrom != "" {
+		return r.HeaderFrom
+	}
+	return r.Policy.Domain
+}
+
+// DKIMAlignment classifies the DKIM signatures of the record
+func (r *Record) DKIMAlignment() string {
+	class := AuthFail
+	mode := r.Policy.Normalized().ADKIM
+	for _, d := range r.DKIMAuth {
+		if d.Result != "pass" {
+			continue
+		}
+		if Aligned(d.Domain, r.FromDomain(), mode) {
+			return AlignedPass
+		}
+		class = UnalignedPass
+	}
+	return class
+}
+
+// SPFAlignment classifies the SPF checks of the record
+func (r *Record) SPFAlignment() string {
+	class := AuthFail
+	mode := r.Policy.Normalized().ASPF
+	for _, s := range r.SPFAuth {
+		if s.Result != "pass" {
+			continue
+		}
+		if Aligned(s.Domain, r.FromDomain(), mode) {
+			return AlignedPass
+		}
+		class = UnalignedPass
+	}
+	return class
+}
+
+// Alignment is the best class of DKIM and SPF: a record is aligned when
+// one of them is (DMARC pass)
+func (r *Record) Alignment() string {
+	dkim, spf := r.DKIMAlignment(), r.SPFAlignment()
+	switch {
+	case dkim == AlignedPass || spf == AlignedPass:
+		return AlignedPass
+	case dkim == UnalignedPass || spf == UnalignedPass:
+		return UnalignedPass
+	}
+	return AuthFail
+}
+
+// UnalignedDomains returns the domains that authenticated the record
+// without being aligned
+func (r *Record) UnalignedDomains() []string {
+	out := make([]string, 0)
+	for _, d := range r.DKIMAuth {
+		if d.Result == "pass" && !Aligned(d.Domain, r.FromDomain(), r.Policy.Normalized().ADKIM) {
+			out = append(out, d.Domain)
+		}
+	}
+	for _, s := range r.SPFAuth {
+		if s.Result == "pass" && !Aligned(s.Domain, r.FromDomain(), r.Policy.Normalized().ASPF) {
+			out = append(out, s.Domain)
+		}
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package dmarc
+
+import "testing"
+
+func TestAligned(t *testing.T) {
+	tests := []struct {
+		domain, from string
+		strict       bool
+		relaxed      bool
+	}{
+		{"example.com", "example.com", true, true},
+		{"EXAMPLE.com.", "example.com", true, true},
+		{"mail.example.com", "example.com", false, true},
+		{"example.com", "news.example.com", false, true},
+		{"a.example.co.uk", "b.example.co.uk", false, true},
+		{"example.co.uk", "other.co.uk", false, false},
+		{"example.org", "example.com", false, false},
+		{"sendgrid.net", "example.com", false, false},
+		{"", "example.com", false, false},
+	}
+	for _, tt := range tests {
+		if got := Aligned(tt.domain, tt.from, "s"); got != tt.strict {
+			t.Errorf("Aligned(%q, %q, s) = %v", tt.domain, tt.from, got)
+		}
+		if got := Aligned(tt.domain, tt.from, "r"); got != tt.relaxed {
+			t.Errorf("Aligned(%q, %q, r) = %v", tt.domain, tt.from, got)
+		}
+	}
+}
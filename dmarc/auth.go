@@ -0,0 +1,86 @@
+package dmarc
+
+import (
+	"strings"
+)
+
+// DKIMAuth is a DKIM signature evaluated by the receiver (auth_results)
+type DKIMAuth struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector"`
+	Result   Result `json:"result"`
+	// value as reported, and its explanation
+	RawResult   string `json:"raw_result"`
+	HumanResult string `json:"human_result"`
+}
+
+// SPFAuth is an SPF check done by the receiver (auth_results), the domain
+// being the one of the MAIL FROM (or HELO)
+type SPFAuth struct {
+	Domain    string `json:"domain"`
+	Result    Result `json:"result"`
+	RawResult string `json:"raw_result"`
+}
+
+func newAuthResults(a *AuthResultType) ([]*DKIMAuth, []*SPFAuth) {
+	dkim := make([]*DKIMAuth, 0)
+	spf := make([]*SPFAuth, 0)
+	if a == nil {
+		return dkim, spf
+	}
+	for _, d := range a.Dkim {
+		dkim = append(dkim, &DKIMAuth{
+			Domain:      normalizeName(d.Domain),
+			Selector:    strings.TrimSpace(d.Selector),
+			Result:      ParseResult(d.Result),
+			RawResult:   strings.TrimSpace(d.Result),
+			HumanResult: strings.TrimSpace(d.Humanresult),
+		})
+	}
+	for _, s := range a.Spf {
+		spf = append(spf, &SPFAuth{
+			Domain:    normalizeName(s.Domain),
+			Result:    ParseResult(s.Result),
+			RawResult: strings.TrimSpace(s.Result),
+		})
+	}
+	return dkim, spf
+}
+
+// DKIMDomains returns the domains of the DKIM signatures. With pass, only
+// the signatures that passed are considered.
+func (r *Record) DKIMDomains(pass bool) []string {
+	out := make([]string, 0, len(r.DKIMAuth))
+	for _, d := range r.DKIMAuth {
+		if !pass || d.Result == "pass" {
+			out = append(out, d.Domain)
+		}
+	}
+	return out
+}
+
+// DKIMSelectors returns the selectors of the DKIM signatures
+func (r *Record) DKIMSelectors() []string {
+	out := make([]string, 0, len(r.DKIMAuth))
+	for _, d := range r.DKIMAuth {
+		out = append(out, d.Selector)
+	}
+	return out
+}
+
+// SPFDomains returns the domains checked by SPF
+func (r *Record) SPFDomains() []string {
+	out := make([]string, 0, len(r.SPFAuth))
+	for _, s := range r.SPFAuth {
+		out = append(out, s.Domain)
+	}
+	return out
+}
+
+// SPFDomain returns the domain of the first SPF check ("" if none)
+func (r *Record) SPFDomain() string {
+	if len(r.SPFAuth) == 0 {
+		return ""
+	}
+	return r.SPFAuth[0].Domain
+}
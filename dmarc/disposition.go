@@ -0,0 +1,70 @@
+package dmarc
+
+import (
+	"strings"
+)
+
+// OverrideReason is a reason given by a receiver for not applying the
+// published policy (forwarded, sampled_out, trusted_forwarder,
+// mailing_list, local_policy or other)
+type OverrideReason struct {
+	Type    string `json:"type"`
+	Comment string `json:"comment"`
+}
+
+func newOverrideReasons(reasons []*PolicyOverrideReason) []*OverrideReason {
+	out := make([]*OverrideReason, 0, len(reasons))
+	for _, r := range reasons {
+		if r == nil {
+			continue
+		}
+		out = append(out, &OverrideReason{
+			Type:    strings.ToLower(strings.TrimSpace(r.Type)),
+			Comment: strings.TrimSpace(r.Comment),
+		})
+	}
+	return out
+}
+
+// dispositionRank orders the dispositions from the most lenient
+var dispositionRank = map[string]int{"none": 0, "quarantine": 1, "reject": 2}
+
+// ReasonTypes returns the types of the override reasons
+func (r *Record) ReasonTypes() []string {
+	out := make([]string, 0, len(r.Reasons))
+	for _, x := range r.Reasons {
+		out = append(out, x.Type)
+	}
+	return out
+}
+
+// ReasonComments returns the (non empty) comments of the override reasons
+func (r *Record) ReasonComments() []string {
+	out := make([]string, 0, len(r.Reasons))
+	for _, x := range r.Reasons {
+		if x.Comment != "" {
+			out = append(out, x.Comment)
+		}
+	}
+	return out
+}
+
+// ExpectedDisposition is the disposition requested by the published policy
+// (see AppliedPolicy). It is "none" when the messages passed DMARC.
+func (r *Record) ExpectedDisposition() string {
+	if r.Pass() {
+		return "none"
+	}
+	return r.AppliedPolicy()
+}
+
+// Overridden tells whether the receiver did not apply the published policy:
+// it gave override reasons or applied a more lenient disposition
+func (r *Record) Overridden() bool {
+	if len(r.Reasons) > 0 {
+		return true
+	}
+	expected, known := dispositionRank[r.ExpectedDisposition()]
+	applied, known2 := dispositionRank[r.Disposition]
+	return known && known2 && applied < expected
+}
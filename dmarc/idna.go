@@ -0,0 +1,36 @@
+package dmarc
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// lenient lookup profile: underscores are common in DNS names (_dmarc,
+// _domainkey) and must not make a name invalid
+var idnaProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// CanonicalDomain returns the canonical form of a domain name: lowercased
+// A-labels (punycode) without trailing dot. Names that are not valid IDNs
+// are only lowercased.
+func CanonicalDomain(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if ascii, err := idnaProfile.ToASCII(name); err == nil {
+		return ascii
+	}
+	return name
+}
+
+// DisplayDomain returns the Unicode form (U-labels) of a domain name
+func DisplayDomain(name string) string {
+	name = CanonicalDomain(name)
+	if unicode, err := idnaProfile.ToUnicode(name); err == nil {
+		return unicode
+	}
+	return name
+}
+
+// normalizeName returns the canonical form of a domain name
+func normalizeName(name string) string {
+	return CanonicalDomain(name)
+}
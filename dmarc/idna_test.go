@@ -0,0 +1,25 @@
+package dmarc
+
+import "testing"
+
+func TestDomainForms(t *testing.T) {
+	tests := []struct {
+		name, canonical, display string
+	}{
+		{"Example.COM.", "example.com", "example.com"},
+		{"bücher.de", "xn--bcher-kva.de", "bücher.de"},
+		{"XN--BCHER-KVA.DE", "xn--bcher-kva.de", "bücher.de"},
+		{"BÜCHER.de", "xn--bcher-kva.de", "bücher.de"},
+		{"mail.münchen.de", "mail.xn--mnchen-3ya.de", "mail.münchen.de"},
+		{"_dmarc.example.com", "_dmarc.example.com", "_dmarc.example.com"},
+		{" s1._domainkey.example.com ", "s1._domainkey.example.com", "s1._domainkey.example.com"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalDomain(tt.name); got != tt.canonical {
+			t.Errorf("CanonicalDomain(%q) = %q, want %q", tt.name, got, tt.canonical)
+		}
+		if got := DisplayDomain(tt.name); got != tt.display {
+			t.Errorf("DisplayDomain(%q) = %q, want %q", tt.name, got, tt.display)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package dmarc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Policy is a DMARC policy, either published in DNS or as seen by a
+// receiver (policy_published element of the reports)
+type Policy struct {
+	Domain string `json:"domain"`
+	ADKIM  string `json:"adkim"`
+	ASPF   string `json:"aspf"`
+	P      string `json:"p"`
+	SP     string `json:"sp"`
+	// 100 when the tag (or the pct element of a report) is absent
+	Pct int `json:"pct"`
+}
+
+func newPolicy(p *PolicyPublishedType) Policy {
+	if p == nil {
+		return Policy{}
+	}
+	policy := Policy{
+		Domain: normalizeName(p.Domain),
+		ADKIM:  strings.ToLower(p.Adkim),
+		ASPF:   strings.ToLower(p.Aspf),
+		P:      strings.ToLower(p.P),
+		SP:     strings.ToLower(p.Sp),
+		Pct:    100,
+	}
+	if p.Pct != nil {
+		policy.Pct = *p.Pct
+	}
+	return policy
+}
+
+// Normalized fills the optional tags with their default values
+func (p Policy) Normalized() Policy {
+	if p.SP == "" {
+		p.SP = p.P
+	}
+	if p.ADKIM == "" {
+		p.ADKIM = "r"
+	}
+	if p.ASPF == "" {
+		p.ASPF = "r"
+	}
+	return p
+}
+
+// Equal compares the tags of two policies (not the domains)
+func (p Policy) Equal(other Policy) bool {
+	a, b := p.Normalized(), other.Normalized()
+	a.Domain, b.Domain = "", ""
+	return a == b
+}
+
+func (p Policy) String() string {
+	if p.P == "" {
+		return "-"
+	}
+	n := p.Normalized()
+	return fmt.Sprintf("p=%s sp=%s pct=%d adkim=%s aspf=%s", n.P, n.SP, n.Pct, n.ADKIM, n.ASPF)
+}
+
+// ParseDMARCRecord parses a DMARC TXT record (RFC 7489 section 6.3)
+func ParseDMARCRecord(domain, record string) (Policy, error) {
+	p := Policy{Domain: normalizeName(domain), Pct: 100}
+	for i, tag := range strings.Split(record, ";") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		name, value, found := strings.Cut(tag, "=")
+		if !found {
+			return p, fmt.Errorf("invalid tag %q", tag)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if i == 0 {
+			if name != "v" || !strings.EqualFold(value, "DMARC1") {
+				return p, fmt.Errorf("the record does not start with v=DMARC1")
+			}
+			continue
+		}
+		switch name {
+		case "p":
+			p.P = strings.ToLower(value)
+		case "sp":
+			p.SP = strings.ToLower(value)
+		case "adkim":
+			p.ADKIM = strings.ToLower(value)
+		case "aspf":
+			p.ASPF = strings.ToLower(value)
+		case "pct":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 100 {
+				return p, fmt.Errorf("invalid pct %q", value)
+			}
+			p.Pct = n
+		}
+	}
+	if p.P == "" {
+		return p, fmt.Errorf("missing p tag")
+	}
+	return p, nil
+}
+
+// AppliedTag tells which tag of the policy applies to the record: "p" when
+// the policy was published by the header_from domain itself, "sp" when it
+// was found at the organizational domain of a subdomain
+func (r *Record) AppliedTag() string {
+	from := normalizeName(r.HeaderFrom)
+	if from == "" || from == r.Policy.Domain || OrgDomain(from) != r.Policy.Domain {
+		return "p"
+	}
+	return "sp"
+}
+
+// AppliedPolicy returns the value of the tag that applies to the record
+func (r *Record) AppliedPolicy() string {
+	p := r.Policy.Normalized()
+	if r.AppliedTag() == "sp" {
+		return p.SP
+	}
+	return p.P
+}
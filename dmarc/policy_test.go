@@ -0,0 +1,74 @@
+package dmarc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// reportXML builds a report of example.com with the given policy_published
+// elements and records
+func reportXML(policy string, records ...string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feedback>
+<report_metadata><org_name>example.net</org_name><email>dmarc@example.net</email><report_id>1</report_id>
+<date_range><begin>1672531200</begin><end>1672617600</end></date_range></report_metadata>
+<policy_published><domain>example.com</domain>%s</policy_published>
+%s
+</feedback>
+`, policy, strings.Join(records, "\n"))
+}
+
+func TestReportPct(t *testing.T) {
+	tests := []struct {
+		policy string
+		pct    int
+	}{
+		{"<p>quarantine</p><pct>50</pct>", 50},
+		{"<p>quarantine</p><pct>0</pct>", 0},
+		{"<p>quarantine</p>", 100},
+	}
+	for _, tt := range tests {
+		report, err := Parse(strings.NewReader(reportXML(tt.policy)))
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", tt.policy, err)
+		}
+		if report.Policy.Pct != tt.pct || report.Policy.Normalized().Pct != tt.pct {
+			t.Errorf("%s: pct %d (normalized %d), want %d", tt.policy, report.Policy.Pct, report.Policy.Normalized().Pct, tt.pct)
+		}
+	}
+}
+
+func TestPolicyEqual(t *testing.T) {
+	p := Policy{P: "quarantine", Pct: 100}
+	if !p.Equal(Policy{P: "quarantine", SP: "quarantine", ADKIM: "r", ASPF: "r", Pct: 100}) {
+		t.Error("the default tags differ")
+	}
+	if p.Equal(Policy{P: "quarantine", Pct: 0}) {
+		t.Error("pct=0 equals pct=100")
+	}
+}
+
+func TestParseDMARCRecord(t *testing.T) {
+	tests := []struct {
+		record string
+		want   Policy
+		err    bool
+	}{
+		{"v=DMARC1; p=reject", Policy{Domain: "example.com", P: "reject", Pct: 100}, false},
+		{"v=dmarc1;p=Quarantine;pct=0;sp=none;adkim=s", Policy{Domain: "example.com", P: "quarantine", SP: "none", ADKIM: "s", Pct: 0}, false},
+		{"v=DMARC1; pct=50", Policy{}, true},
+		{"v=DMARC1; p=none; pct=101", Policy{}, true},
+		{"p=none; v=DMARC1", Policy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDMARCRecord("Example.COM", tt.record)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseDMARCRecord(%q): error %v", tt.record, err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("ParseDMARCRecord(%q) = %+v, want %+v", tt.record, got, tt.want)
+		}
+	}
+}
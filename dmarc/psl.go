@@ -0,0 +1,120 @@
+package dmarc
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+)
+
+// snapshot of https://publicsuffix.org/list/public_suffix_list.dat
+//
+//go:embed public_suffix_list.dat
+var suffixSnapshot []byte
+
+// SuffixList is a Public Suffix List, used to find the organizational
+// domain of a name (RFC 7489 section 3.2)
+type SuffixList struct {
+	rules      map[string]bool
+	wildcards  map[string]bool // *.<name>
+	exceptions map[string]bool // !<name>
+}
+
+// ParseSuffixList reads a list in the publicsuffix.org format (one rule
+// per line, comments starting with //)
+func ParseSuffixList(r io.Reader) (*SuffixList, error) {
+	l := &SuffixList{
+		rules:      make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// a rule stops at the first whitespace
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+		rule := fields[0]
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			l.exceptions[normalizeName(rule[1:])] = true
+		case strings.HasPrefix(rule, "*."):
+			l.wildcards[normalizeName(rule[2:])] = true
+		default:
+			l.rules[normalizeName(rule)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LoadSuffixList reads a list from a file
+func LoadSuffixList(path string) (*SuffixList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSuffixList(file)
+}
+
+// DefaultSuffixList returns the embedded snapshot of the list
+func DefaultSuffixList() *SuffixList {
+	l, err := ParseSuffixList(bytes.NewReader(suffixSnapshot))
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Extend adds the rules of other to the list
+func (l *SuffixList) Extend(other *SuffixList) {
+	for r := range other.rules {
+		l.rules[r] = true
+	}
+	for r := range other.wildcards {
+		l.wildcards[r] = true
+	}
+	for r := range other.exceptions {
+		l.exceptions[r] = true
+	}
+}
+
+// PublicSuffix returns the public suffix of a name, the longest matching
+// rule winning (the TLD if no rule matches)
+func (l *SuffixList) PublicSuffix(name string) string {
+	labels := strings.Split(normalizeName(name), ".")
+	for i := range labels {
+		suffix := strings.Join(labels[i:], ".")
+		if l.exceptions[suffix] {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if l.rules[suffix] {
+			return suffix
+		}
+		if i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")] {
+			return suffix
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// OrgDomain returns the organizational domain of a name: its public suffix
+// and one more label (the name itself if it is a public suffix)
+func (l *SuffixList) OrgDomain(name string) string {
+	name = normalizeName(name)
+	suffix := l.PublicSuffix(name)
+	if name == suffix {
+		return name
+	}
+	rest := strings.TrimSuffix(name, "."+suffix)
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix
+}
+
+// Suffixes is the list used by OrgDomain (the embedded snapshot by default)
+var Suffixes = DefaultSuffixList()
@@ -0,0 +1,81 @@
+package dmarc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuffixList(t *testing.T) {
+	l, err := ParseSuffixList(strings.NewReader(`// rules
+com
+co.uk
+uk
+*.ck
+!www.ck
+*.kawasaki.jp
+!city.kawasaki.jp
+jp
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, suffix, org string
+	}{
+		{"mail.example.com", "com", "example.com"},
+		{"example.com", "com", "example.com"},
+		{"com", "com", "com"},
+		{"a.b.example.co.uk", "co.uk", "example.co.uk"},
+		{"example.uk", "uk", "example.uk"},
+		// wildcard: every label below ck is a public suffix
+		{"mail.example.foo.ck", "foo.ck", "example.foo.ck"},
+		{"foo.ck", "foo.ck", "foo.ck"},
+		// exception to the wildcard
+		{"www.ck", "ck", "www.ck"},
+		{"mail.www.ck", "ck", "www.ck"},
+		{"a.b.kawasaki.jp", "b.kawasaki.jp", "a.b.kawasaki.jp"},
+		{"mail.city.kawasaki.jp", "kawasaki.jp", "city.kawasaki.jp"},
+		// no rule: the TLD
+		{"Mail.Example.ORG.", "org", "example.org"},
+	}
+	for _, tt := range tests {
+		if got := l.PublicSuffix(tt.name); got != tt.suffix {
+			t.Errorf("PublicSuffix(%s) = %s, want %s", tt.name, got, tt.suffix)
+		}
+		if got := l.OrgDomain(tt.name); got != tt.org {
+			t.Errorf("OrgDomain(%s) = %s, want %s", tt.name, got, tt.org)
+		}
+	}
+}
+
+func TestSuffixListExtend(t *testing.T) {
+	l := DefaultSuffixList()
+	if got := l.OrgDomain("a.b.corp.example"); got != "corp.example" {
+		t.Fatalf("OrgDomain = %s before Extend", got)
+	}
+	extra, err := ParseSuffixList(strings.NewReader("corp.example\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Extend(extra)
+	if got := l.OrgDomain("a.b.corp.example"); got != "b.corp.example" {
+		t.Errorf("OrgDomain = %s after Extend", got)
+	}
+}
+
+func TestOrgDomain(t *testing.T) {
+	tests := []struct {
+		name, org string
+	}{
+		{"mail.example.co.uk", "example.co.uk"},
+		{"a.b.example.com", "example.com"},
+		{"www.ck", "www.ck"},
+		{"mail.example.foo.ck", "example.foo.ck"},
+		{"mail.bücher.de", "xn--bcher-kva.de"},
+	}
+	for _, tt := range tests {
+		if got := OrgDomain(tt.name); got != tt.org {
+			t.Errorf("OrgDomain(%s) = %s, want %s", tt.name, got, tt.org)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package dmarc reads DMARC aggregate reports (RFC 7489) and analyses their
+// records (alignment, policy overrides...).
+//
+//	report, err := dmarc.Open("google.com!example.com!1672531200!1672617599.zip")
+//	if err != nil {
+//		return err
+//	}
+//	for _, record := range report.Records {
+//		fmt.Println(record.SourceIP, record.Count, record.Alignment())
+//	}
+package dmarc
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/h2non/filetype"
+	"github.com/h2non/filetype/matchers"
+)
+
+// ErrNotReport is returned when the content is not a DMARC aggregate report
+var ErrNotReport = errors.New("not a DMARC report")
+
+// type for XML DMARC report
+var dmarcType = filetype.NewType("dmarc", "application/dmarc")
+
+func dmarcMatcher(data []byte) bool {
+	buffer := bytes.NewBuffer(data)
+	scanner := bufio.NewScanner(buffer)
+	// skip first line (xml type)
+	if !scanner.Scan() {
+		return false
+	}
+	if scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "<feedback>") {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	// Register the new matcher and its type
+	filetype.AddMatcher(dmarcType, dmarcMatcher)
+}
+
+// Decode returns the XML content of a report, either raw or compressed
+// (gzip, or zip with a single file). Only the header of the content is
+// read when it is not a report.
+func Decode(r io.Reader) ([]byte, error) {
+	buffered := bufio.NewReader(r)
+	// the signatures lie in the first 262 bytes
+	head, err := buffered.Peek(262)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(head) == 0 {
+		return nil, ErrNotReport
+	}
+	t, err := filetype.Match(head)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case matchers.TypeGz:
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		return readReport(gzipReader)
+	case matchers.TypeZip:
+		// the central directory is at the end of the archive
+		data, err := io.ReadAll(buffered)
+		if err != nil {
+			return nil, err
+		}
+		zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		n := len(zipReader.File)
+		if n != 1 {
+			return nil, fmt.Errorf("%w: the zip archive has not a single file (%d)", ErrNotReport, n)
+		}
+		fileReader, err := zipReader.File[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer fileReader.Close()
+		return readReport(fileReader)
+	}
+	// assume raw case
+	return readReport(buffered)
+}
+
+// readReport reads the XML content of a report, after checking its header
+func readReport(r io.Reader) ([]byte, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(128)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !filetype.IsType(header, dmarcType) {
+		return nil, ErrNotReport
+	}
+	return io.ReadAll(buffered)
+}
+
+// Parse reads a report, either raw XML or compressed
+func Parse(r io.Reader) (*Report, error) {
+	content, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	feedback := Feedback{}
+	if err := xml.Unmarshal(content, &feedback); err != nil {
+		return nil, err
+	}
+	return NewReport(&feedback), nil
+}
+
+// Open reads the report stored in a file
+func Open(path string) (*Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	report, err := Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	report.File = path
+	return report, nil
+}
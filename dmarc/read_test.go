@@ -0,0 +1,103 @@
+package dmarc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testRecord = `<record><row><source_ip>192.0.2.1</source_ip><count>3</count>
+<policy_evaluated><disposition>none</disposition><dkim>pass</dkim><spf>fail</spf></policy_evaluated></row>
+<identifiers><header_from>example.com</header_from></identifiers>
+<auth_results><dkim><domain>example.com</domain><selector>s1</selector><result>pass</result></dkim>
+<spf><domain>example.org</domain><result>pass</result></spf></auth_results></record>`
+
+func gzipped(t *testing.T, data string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func zipped(t *testing.T, files ...string) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for i, data := range files {
+		f, err := w.Create(strings.Repeat("r", i+1) + ".xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	report := reportXML("<p>none</p>", testRecord)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"raw", []byte(report)},
+		{"gzip", gzipped(t, report)},
+		{"zip", zipped(t, report)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.OrgName != "example.net" || r.Policy.Domain != "example.com" || len(r.Records) != 1 {
+				t.Fatalf("report %+v", r)
+			}
+			rec := r.Records[0]
+			if rec.Count != 3 || rec.SourceIP.String() != "192.0.2.1" || rec.DKIMResult != "pass" || len(rec.SPFAuth) != 1 {
+				t.Errorf("record %+v", rec)
+			}
+		})
+	}
+}
+
+func TestParseNotReport(t *testing.T) {
+	other := "<?xml version=\"1.0\"?>\n<html><body/></html>\n"
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"text", []byte("hello\nworld\n")},
+		{"other xml", []byte(other)},
+		{"gzip of other", gzipped(t, other)},
+		{"zip of other", zipped(t, other)},
+		{"zip of two reports", zipped(t, reportXML("<p>none</p>"), reportXML("<p>none</p>"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(bytes.NewReader(tt.data)); !errors.Is(err, ErrNotReport) {
+				t.Errorf("error %v, want ErrNotReport", err)
+			}
+		})
+	}
+}
+
+func TestParseBroken(t *testing.T) {
+	broken := reportXML("<p>none</p>", testRecord)
+	broken = broken[:len(broken)/2]
+	if _, err := Parse(strings.NewReader(broken)); err == nil || errors.Is(err, ErrNotReport) {
+		t.Errorf("error %v, want an XML error", err)
+	}
+}
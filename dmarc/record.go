@@ -0,0 +1,50 @@
+package dmarc
+
+import (
+	"encoding/xml"
+	"net"
+	"strings"
+)
+
+// Record is a row of a report: the messages sent from a source IP that
+// share the same identifiers and authentication results
+type Record struct {
+	SourceIP   net.IP `json:"source_ip"`
+	Count      int    `json:"count"`
+	EnvelopeTo string `json:"envelope_to"`
+	HeaderFrom string `json:"header_from"`
+	// DMARC evaluation of the receiver (policy_evaluated): aligned DKIM and
+	// SPF results, disposition applied and why it overrode the policy
+	DKIMResult  string            `json:"dkim"`
+	SPFResult   string            `json:"spf"`
+	Disposition string            `json:"disposition"`
+	Reasons     []*OverrideReason `json:"reasons"`
+	// authentication results (auth_results) as evaluated by the receiver
+	DKIMAuth []*DKIMAuth `json:"dkim_auth"`
+	SPFAuth  []*SPFAuth  `json:"spf_auth"`
+	// policy published by the domain (the one of the report)
+	Policy Policy `json:"policy"`
+	// XML of the record
+	XML []byte `json:"xml"`
+}
+
+func newRecord(record *RecordType, policy Policy) *Record {
+	r := &Record{Policy: policy}
+	r.XML, _ = xml.MarshalIndent(record, "", "  ")
+	if row := record.Row; row != nil {
+		r.SourceIP = net.ParseIP(strings.TrimSpace(row.Sourceip))
+		r.Count = row.Count
+		if e := row.Policyevaluated; e != nil {
+			r.DKIMResult = strings.ToLower(strings.TrimSpace(e.Dkim))
+			r.SPFResult = strings.ToLower(strings.TrimSpace(e.Spf))
+			r.Disposition = strings.ToLower(strings.TrimSpace(e.Disposition))
+			r.Reasons = newOverrideReasons(e.Reason)
+		}
+	}
+	if id := record.Identifiers; id != nil {
+		r.EnvelopeTo = normalizeName(id.Envelopeto)
+		r.HeaderFrom = normalizeName(id.Headerfrom)
+	}
+	r.DKIMAuth, r.SPFAuth = newAuthResults(record.Authresults)
+	return r
+}
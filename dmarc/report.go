@@ -0,0 +1,59 @@
+package dmarc
+
+import (
+	"strings"
+	"time"
+)
+
+// Report is an aggregate report as sent by a receiver
+type Report struct {
+	// file the report was read from (empty if parsed from a stream)
+	File             string    `json:"file"`
+	OrgName          string    `json:"org_name"`
+	Email            string    `json:"email"`
+	ExtraContactInfo string    `json:"extra_contact_info"`
+	ReportID         string    `json:"report_id"`
+	Begin            time.Time `json:"begin"`
+	End              time.Time `json:"end"`
+	// policy published by the domain, as seen by the receiver
+	Policy Policy `json:"policy"`
+	// errors encountered by the reporter while producing the report
+	Errors  []string  `json:"errors"`
+	Records []*Record `json:"records"`
+}
+
+// NewReport builds a report from its XML representation
+func NewReport(feedback *Feedback) *Report {
+	report := &Report{Policy: newPolicy(feedback.Policypublished)}
+	if meta := feedback.Reportmetadata; meta != nil {
+		report.OrgName = meta.Orgname
+		report.Email = strings.TrimSpace(meta.Email)
+		report.ExtraContactInfo = strings.TrimSpace(meta.Extracontactinfo)
+		report.ReportID = meta.Reportid
+		if meta.Daterange != nil {
+			report.Begin = time.Unix(int64(meta.Daterange.Begin), 0)
+			report.End = time.Unix(int64(meta.Daterange.End), 0)
+		}
+		for _, e := range meta.Error {
+			if e = strings.TrimSpace(e); e != "" {
+				report.Errors = append(report.Errors, e)
+			}
+		}
+	}
+	for _, record := range feedback.Record {
+		if record == nil {
+			continue
+		}
+		report.Records = append(report.Records, newRecord(record, report.Policy))
+	}
+	return report
+}
+
+// Messages returns the number of messages covered by the report
+func (r *Report) Messages() int {
+	n := 0
+	for _, record := range r.Records {
+		n += record.Count
+	}
+	return n
+}
@@ -0,0 +1,108 @@
+package dmarc
+
+import (
+	"strings"
+)
+
+// Result is a normalised authentication result (RFC 8601 section 2.7)
+type Result string
+
+const (
+	ResultPass      Result = "pass"
+	ResultFail      Result = "fail"
+	ResultSoftFail  Result = "softfail"
+	ResultNeutral   Result = "neutral"
+	ResultNone      Result = "none"
+	ResultPolicy    Result = "policy"
+	ResultTempError Result = "temperror"
+	ResultPermError Result = "permerror"
+	// value that cannot be mapped
+	ResultUnknown Result = "unknown"
+)
+
+// resultAliases maps the values seen in the wild to the results
+var resultAliases = map[string]Result{
+	"pass":       ResultPass,
+	"fail":       ResultFail,
+	"hardfail":   ResultFail,
+	"softfail":   ResultSoftFail,
+	"neutral":    ResultNeutral,
+	"none":       ResultNone,
+	"policy":     ResultPolicy,
+	"temperror":  ResultTempError,
+	"temp_error": ResultTempError,
+	"tempfail":   ResultTempError,
+	"permerror":  ResultPermError,
+	"perm_error": ResultPermError,
+	"permfail":   ResultPermError,
+}
+
+// ParseResult normalises a raw result value
+func ParseResult(raw string) Result {
+	if r, exists := resultAliases[strings.ToLower(strings.TrimSpace(raw))]; exists {
+		return r
+	}
+	return ResultUnknown
+}
+
+// classes of results
+const (
+	ClassPass = "pass"
+	// failures that will happen again (fail, softfail, permerror, policy)
+	ClassPermanent = "permanent"
+	// failures that may not happen again (temperror)
+	ClassTransient = "transient"
+	// no verdict (neutral, none, unknown)
+	ClassNeutral = "neutral"
+)
+
+// Class returns the class of the result
+func (r Result) Class() string {
+	switch r {
+	case ResultPass:
+		return ClassPass
+	case ResultFail, ResultSoftFail, ResultPermError, ResultPolicy:
+		return ClassPermanent
+	case ResultTempError:
+		return ClassTransient
+	}
+	return ClassNeutral
+}
+
+// Pass tells whether the receiver evaluated the record as passing DMARC
+// (one of the aligned mechanisms passed)
+func (r *Record) Pass() bool {
+	return r.DKIMResult == "pass" || r.SPFResult == "pass"
+}
+
+// Transient tells whether the record failed DMARC while one of its
+// authentication results is a transient error: the failure may not happen
+// again, so it should not weigh in pass rates
+func (r *Record) Transient() bool {
+	if r.Pass() {
+		return false
+	}
+	for _, d := range r.DKIMAuth {
+		if d.Result.Class() == ClassTransient {
+			return true
+		}
+	}
+	for _, s := range r.SPFAuth {
+		if s.Result.Class() == ClassTransient {
+			return true
+		}
+	}
+	return false
+}
+
+// Failure classifies the DMARC failure of the record: "none" when it
+// passed, ClassTransient or ClassPermanent otherwise
+func (r *Record) Failure() string {
+	switch {
+	case r.Pass():
+		return "none"
+	case r.Transient():
+		return ClassTransient
+	}
+	return ClassPermanent
+}
@@ -0,0 +1,34 @@
+package dmarc
+
+import "testing"
+
+func TestParseResult(t *testing.T) {
+	tests := []struct {
+		raw   string
+		want  Result
+		class string
+	}{
+		{"pass", ResultPass, ClassPass},
+		{" PASS ", ResultPass, ClassPass},
+		{"hardfail", ResultFail, ClassPermanent},
+		{"SoftFail", ResultSoftFail, ClassPermanent},
+		{"policy", ResultPolicy, ClassPermanent},
+		{"temp_error", ResultTempError, ClassTransient},
+		{"tempfail", ResultTempError, ClassTransient},
+		{"perm_error", ResultPermError, ClassPermanent},
+		{"permfail", ResultPermError, ClassPermanent},
+		{"neutral", ResultNeutral, ClassNeutral},
+		{"none", ResultNone, ClassNeutral},
+		{"", ResultUnknown, ClassNeutral},
+		{"bogus", ResultUnknown, ClassNeutral},
+	}
+	for _, tt := range tests {
+		got := ParseResult(tt.raw)
+		if got != tt.want {
+			t.Errorf("ParseResult(%q) = %s, want %s", tt.raw, got, tt.want)
+		}
+		if got.Class() != tt.class {
+			t.Errorf("%s: class %s, want %s", got, got.Class(), tt.class)
+		}
+	}
+}
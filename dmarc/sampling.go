@@ -0,0 +1,30 @@
+package dmarc
+
+// Enforceable tells whether the policy requests a disposition (quarantine
+// or reject) for the messages of the record, i.e. they failed DMARC
+func (r *Record) Enforceable() bool {
+	_, ok := dispositionRank[r.ExpectedDisposition()]
+	return ok && r.ExpectedDisposition() != "none"
+}
+
+// SampledOut tells whether the receiver reported that the messages were
+// exempted from the policy by the sampling (pct)
+func (r *Record) SampledOut() bool {
+	for _, x := range r.Reasons {
+		if x.Type == "sampled_out" {
+			return true
+		}
+	}
+	return false
+}
+
+// EstimateSampling splits the enforceable messages of the record into the
+// messages the policy should have been applied to and the ones exempted by
+// the sampling, according to the pct of the policy (RFC 7489 section 6.6.4)
+func (r *Record) EstimateSampling() (applied, exempted float64) {
+	if !r.Enforceable() {
+		return 0, 0
+	}
+	applied = float64(r.Count) * float64(r.Policy.Pct) / 100
+	return applied, float64(r.Count) - applied
+}
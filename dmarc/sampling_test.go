@@ -0,0 +1,37 @@
+package dmarc
+
+import (
+	"strings"
+	"testing"
+)
+
+// failingRecord is a record of 10 messages failing DMARC, as received
+// with the given disposition
+func failingRecord(disposition string) string {
+	return `<record><row><source_ip>192.0.2.1</source_ip><count>10</count>
+<policy_evaluated><disposition>` + disposition + `</disposition><dkim>fail</dkim><spf>fail</spf></policy_evaluated></row>
+<identifiers><header_from>example.com</header_from></identifiers>
+<auth_results><spf><domain>example.com</domain><result>fail</result></spf></auth_results></record>`
+}
+
+func TestEstimateSampling(t *testing.T) {
+	tests := []struct {
+		policy            string
+		applied, exempted float64
+	}{
+		{"<p>quarantine</p><pct>0</pct>", 0, 10},
+		{"<p>quarantine</p><pct>50</pct>", 5, 5},
+		{"<p>quarantine</p>", 10, 0},
+		{"<p>none</p><pct>50</pct>", 0, 0},
+	}
+	for _, tt := range tests {
+		report, err := Parse(strings.NewReader(reportXML(tt.policy, failingRecord("none"))))
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", tt.policy, err)
+		}
+		applied, exempted := report.Records[0].EstimateSampling()
+		if applied != tt.applied || exempted != tt.exempted {
+			t.Errorf("%s: %.1f applied, %.1f exempted, want %.1f, %.1f", tt.policy, applied, exempted, tt.applied, tt.exempted)
+		}
+	}
+}
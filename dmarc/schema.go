@@ -1,6 +1,6 @@
 // Code generated by xgen. DO NOT EDIT.
 
-package main
+package dmarc
 
 import (
 	"encoding/xml"
@@ -35,7 +35,7 @@ type PolicyPublishedType struct {
 	Aspf   string `xml:"aspf"`
 	P      string `xml:"p"`
 	Sp     string `xml:"sp"`
-	Pct    int    `xml:"pct"`
+	Pct    *int   `xml:"pct"`
 }
 
 // DMARCResultType ...
@@ -0,0 +1,103 @@
+package dmarc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// WalkFunc is called for every file of a tree, err being set when the file
+// cannot be read as a report (ErrNotReport for other files). Returning an
+// error stops the walk.
+type WalkFunc func(path string, report *Report, err error) error
+
+// Walk reads the reports of a directory tree, in lexical order
+func Walk(dir string, fn WalkFunc) error {
+	return filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(path, nil, err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		report, err := Open(path)
+		return fn(path, report, err)
+	})
+}
+
+// Iterator goes through the records of the reports of a directory tree,
+// reading the reports one at a time. The files that are not reports are
+// skipped, a report that cannot be read stops the iteration (see Err).
+//
+//	it := dmarc.NewIterator(dir)
+//	for it.Next() {
+//		fmt.Println(it.Report().OrgName, it.Record().SourceIP)
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type Iterator struct {
+	paths  []string
+	report *Report
+	// index of the current record in the report
+	i   int
+	err error
+}
+
+// NewIterator lists the files of the tree, they are read by Next
+func NewIterator(dir string) *Iterator {
+	it := &Iterator{}
+	it.err = filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			it.paths = append(it.paths, path)
+		}
+		return nil
+	})
+	return it
+}
+
+// Next moves to the next record, it returns false at the end of the tree
+func (it *Iterator) Next() bool {
+	for {
+		if it.report != nil && it.i+1 < len(it.report.Records) {
+			it.i++
+			return true
+		}
+		if it.err != nil || len(it.paths) == 0 {
+			return false
+		}
+		path := it.paths[0]
+		it.paths = it.paths[1:]
+		report, err := Open(path)
+		switch {
+		case errors.Is(err, ErrNotReport):
+		case err != nil:
+			it.err = fmt.Errorf("%s: %w", path, err)
+			return false
+		default:
+			it.report, it.i = report, -1
+		}
+	}
+}
+
+// Report returns the report of the current record
+func (it *Iterator) Report() *Report {
+	return it.report
+}
+
+// Record returns the current record
+func (it *Iterator) Record() *Record {
+	if it.report == nil || it.i < 0 {
+		return nil
+	}
+	return it.report.Records[it.i]
+}
+
+// Err returns the error that stopped the iteration (if any)
+func (it *Iterator) Err() error {
+	return it.err
+}
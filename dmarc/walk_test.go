@@ -0,0 +1,72 @@
+package dmarc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestIterator(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.xml":       reportXML("<p>none</p>", testRecord, testRecord),
+		"b/notes.txt": "not a report\n",
+		"b/c.xml":     reportXML("<p>reject</p>", testRecord),
+		"d.xml":       reportXML("<p>none</p>"),
+	})
+	it := NewIterator(dir)
+	policies := make([]string, 0)
+	for it.Next() {
+		if it.Record() == nil {
+			t.Fatal("no record")
+		}
+		policies = append(policies, it.Report().Policy.P)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(policies, ","); got != "none,none,reject" {
+		t.Errorf("records of %s, want none,none,reject", got)
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	broken := reportXML("<p>none</p>", testRecord)
+	dir := writeFiles(t, map[string]string{
+		"a.xml": reportXML("<p>none</p>", testRecord),
+		"b.xml": broken[:len(broken)/2],
+		"c.xml": reportXML("<p>none</p>", testRecord),
+	})
+	it := NewIterator(dir)
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("%d records before the error, want 1", n)
+	}
+	if err := it.Err(); err == nil || !strings.Contains(err.Error(), "b.xml") {
+		t.Errorf("error %v, want an error on b.xml", err)
+	}
+	if it.Next() {
+		t.Error("the iteration goes on after the error")
+	}
+
+	if it := NewIterator(filepath.Join(dir, "missing")); it.Next() || it.Err() == nil {
+		t.Error("no error for a missing directory")
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dnsblRate is the maximum number of DNSBL queries per second
+const dnsblRate = 20
+
+// Blocklist is a DNSBL (IP based) or a RHSBL (domain based). It is either
+// queried through DNS or read from a local rbldnsd zone file.
+type Blocklist struct {
+	// Name is the DNS zone or the name of the zone file
+	Name string
+	// Domain is true for RHSBL
+	Domain bool
+	// data of a local zone file (nil for DNS zones)
+	zone *zoneFile
+}
+
+// zoneFile is the content of a rbldnsd ip4set/ip6trie (IP based) or dnset
+// (domain based) data file
+type zoneFile struct {
+	networks []*net.IPNet
+	excluded []*net.IPNet
+	// exact domains and parent domains whose subdomains are listed
+	domains    map[string]bool
+	subdomains map[string]bool
+}
+
+// NewBlocklist returns the blocklist of a DNS zone, or of a zone file if
+// name is an existing file
+func NewBlocklist(name string, domain bool) (*Blocklist, error) {
+	b := &Blocklist{Name: name, Domain: domain}
+	if _, err := os.Stat(name); err == nil {
+		zone, err := loadZoneFile(name, domain)
+		if err != nil {
+			return nil, err
+		}
+		b.zone = zone
+	} else if strings.ContainsRune(name, os.PathSeparator) {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Local tells whether the blocklist is read from a file
+func (b *Blocklist) Local() bool {
+	return b.zone != nil
+}
+
+// parseListedIP parses the ip4set notations: 1.2.3.4, 1.2.3.0/24 and
+// the prefixes 1.2.3 (/24), 1.2 (/16) and 1 (/8)
+func parseListedIP(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	octets := strings.Split(s, ".")
+	if len(octets) < 4 {
+		full := s + strings.Repeat(".0", 4-len(octets))
+		if ip := net.ParseIP(full); ip != nil {
+			return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(octets), 32)}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid entry %q", s)
+}
+
+func loadZoneFile(path string, domain bool) (*zoneFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zone := &zoneFile{
+		domains:    make(map[string]bool),
+		subdomains: make(map[string]bool),
+	}
+	scanner := bufio.NewScanner(file)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		// comments, directives ($SOA...) and default values (:127.0.0.2:text)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") ||
+			strings.HasPrefix(line, "$") || strings.HasPrefix(line, ":") {
+			continue
+		}
+		// drop the value of the entry (:127.0.0.2:text)
+		entry := strings.Fields(line)[0]
+		exclude := strings.HasPrefix(entry, "!")
+		entry = strings.TrimPrefix(entry, "!")
+
+		if domain {
+			switch {
+			case strings.HasPrefix(entry, "*."):
+				zone.subdomains[normalizeName(entry[2:])] = !exclude
+			case strings.HasPrefix(entry, "."):
+				zone.subdomains[normalizeName(entry[1:])] = !exclude
+			default:
+				zone.domains[normalizeName(entry)] = !exclude
+			}
+			continue
+		}
+		network, err := parseListedIP(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if exclude {
+			zone.excluded = append(zone.excluded, network)
+		} else {
+			zone.networks = append(zone.networks, network)
+		}
+	}
+	return zone, scanner.Err()
+}
+
+func (z *zoneFile) listedIP(ip net.IP) bool {
+	for _, network := range z.excluded {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	for _, network := range z.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *zoneFile) listedDomain(domain string) bool {
+	domain = normalizeName(domain)
+	if listed, exists := z.domains[domain]; exists {
+		return listed
+	}
+	labels := strings.Split(domain, ".")
+	for i := 1; i < len(labels); i++ {
+		if listed, exists := z.subdomains[strings.Join(labels[i:], ".")]; exists {
+			return listed
+		}
+	}
+	return false
+}
+
+// reverseIP returns the DNSBL query label of an IP (4.3.2.1 for 1.2.3.4,
+// reversed nibbles for IPv6)
+func reverseIP(ip net.IP) string {
+	parts := strings.Split(dottedIP(ip), ".")
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, ".")
+}
+
+// blocked is the range of return codes meaning "query refused" (Spamhaus
+// answers 127.255.255.x to open resolvers for instance)
+var _, blocked, _ = net.ParseCIDR("127.255.255.0/24")
+var _, loopback, _ = net.ParseCIDR("127.0.0.0/8")
+
+// Check tells whether the query (an IP for DNSBL, a domain for RHSBL) is
+// listed
+func (b *Blocklist) Check(ctx context.Context, r Resolver, query string) (bool, error) {
+	if b.zone != nil {
+		if b.Domain {
+			return b.zone.listedDomain(query), nil
+		}
+		ip := net.ParseIP(query)
+		return ip != nil && b.zone.listedIP(ip), nil
+	}
+
+	name := normalizeName(query)
+	if !b.Domain {
+		ip := net.ParseIP(query)
+		if ip == nil {
+			return false, fmt.Errorf("invalid IP %q", query)
+		}
+		name = reverseIP(ip)
+	}
+	addrs, err := r.LookupIPAddr(ctx, name+"."+normalizeName(b.Name))
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, a := range addrs {
+		if blocked.Contains(a.IP) {
+			return false, fmt.Errorf("%s refused the query (%s)", b.Name, a.IP)
+		}
+	}
+	for _, a := range addrs {
+		if loopback.Contains(a.IP) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rateLimiter spaces out the DNS queries
+type rateLimiter struct {
+	mu       sync.Mutex
+	next     time.Time
+	interval time.Duration
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{interval: time.Second / time.Duration(perSecond)}
+}
+
+// Wait blocks until the next query is allowed
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var blocklists = make([]*Blocklist, 0)
+
+var dnsblLimiter = newRateLimiter(dnsblRate)
+
+// listing is the cached state of a query in a blocklist, the failed
+// queries (err set) being checked again on the next fetch
+type listing struct {
+	listed bool
+	err    error
+}
+
+// listings caches the checks (key: blocklist|query)
+var listings = struct {
+	sync.Mutex
+	entries map[string]*listing
+}{entries: make(map[string]*listing)}
+
+func getListing(b *Blocklist, query string) *listing {
+	listings.Lock()
+	defer listings.Unlock()
+	return listings.entries[b.Name+"|"+strings.ToLower(query)]
+}
+
+// blocklistQuery returns what must be looked up in the blocklist for the
+// result ("" if nothing)
+func blocklistQuery(b *Blocklist, r *FeedbackResult) string {
+	if b.Domain {
+		return normalizeName(r.Source)
+	}
+	if r.SourceIP == nil {
+		return ""
+	}
+	return r.SourceIP.String()
+}
+
+// Listings returns the blocklists listing the source of the result
+func (r *FeedbackResult) Listings() []string {
+	out := make([]string, 0)
+	for _, b := range blocklists {
+		q := blocklistQuery(b, r)
+		if q == "" {
+			continue
+		}
+		if l := getListing(b, q); l != nil && l.listed {
+			out = append(out, b.Name)
+		}
+	}
+	return out
+}
+
+// ListingStatus is the value of the listed column: "error" when a check
+// failed, "offline" when the DNS zones are not checked (offline mode)
+func (r *FeedbackResult) ListingStatus() string {
+	zones := r.Listings()
+	if len(zones) > 0 {
+		return strings.Join(zones, ",")
+	}
+	failed, skipped := false, false
+	for _, b := range blocklists {
+		q := blocklistQuery(b, r)
+		if q == "" {
+			continue
+		}
+		l := getListing(b, q)
+		switch {
+		case l != nil && l.err != nil:
+			failed = true
+		case l != nil:
+		case offline && !b.Local():
+			skipped = true
+		default:
+			return "…"
+		}
+	}
+	switch {
+	case failed:
+		return "error"
+	case skipped:
+		return "offline"
+	}
+	return "no"
+}
+
+// fetchListings checks in background the sources that are not in the
+// cache yet, or whose check failed
+func fetchListings(results FeedbackResults) tea.Cmd {
+	type check struct {
+		blocklist *Blocklist
+		query     string
+	}
+	checks := make([]check, 0)
+	seen := make(map[string]bool)
+	for _, b := range blocklists {
+		if offline && !b.Local() {
+			continue
+		}
+		for _, r := range results {
+			q := blocklistQuery(b, r)
+			k := b.Name + "|" + strings.ToLower(q)
+			if q == "" || seen[k] {
+				continue
+			}
+			if l := getListing(b, q); l != nil && l.err == nil {
+				continue
+			}
+			seen[k] = true
+			checks = append(checks, check{b, q})
+		}
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		var wg sync.WaitGroup
+		// bound the number of concurrent queries
+		slots := make(chan bool, 8)
+		for _, c := range checks {
+			wg.Add(1)
+			slots <- true
+			go func(b *Blocklist, query string) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				if !b.Local() {
+					if err := dnsblLimiter.Wait(context.Background()); err != nil {
+						return
+					}
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+				defer cancel()
+				listed, err := b.Check(ctx, resolver, query)
+				listings.Lock()
+				listings.entries[b.Name+"|"+strings.ToLower(query)] = &listing{listed: listed, err: err}
+				listings.Unlock()
+			}(c.blocklist, c.query)
+		}
+		wg.Wait()
+		return RefreshViewMsg{}
+	}
+}
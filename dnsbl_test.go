@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestListingStatus(t *testing.T) {
+	dns := &stubResolver{
+		ip:   map[string][]string{"1.2.0.192.bl.example.net": {"127.0.0.2"}},
+		fail: map[string]bool{"3.2.0.192.bl.example.net": true},
+	}
+	saved, savedLists := resolver, blocklists
+	defer func() { resolver, blocklists = saved, savedLists }()
+	resolver = dns
+	blocklists = []*Blocklist{{Name: "bl.example.net"}}
+
+	results := FeedbackResults{}
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		results = append(results, &FeedbackResult{Record: &dmarc.Record{SourceIP: net.ParseIP(ip)}})
+	}
+	if got := results[0].ListingStatus(); got != "…" {
+		t.Errorf("before the checks: %q", got)
+	}
+	fetchListings(results)()
+	for i, want := range []string{"bl.example.net", "no", "error"} {
+		if got := results[i].ListingStatus(); got != want {
+			t.Errorf("%s: %q, want %q", results[i].SourceIP, got, want)
+		}
+	}
+
+	// the failed check is done again
+	delete(dns.fail, "3.2.0.192.bl.example.net")
+	if fetchListings(results) == nil {
+		t.Fatal("the failed check is not done again")
+	}
+	fetchListings(results)()
+	if got := results[2].ListingStatus(); got != "no" {
+		t.Errorf("after a new check: %q", got)
+	}
+	if fetchListings(results) != nil {
+		t.Error("the successful checks are done again")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// domainsSummary rolls the header_from domains up to their organizational
+// domain, every organization expanding into its subdomains. The applied
+// column tells which tag of the policy applied (p for the domain that
+// published the policy, sp for its subdomains).
+func domainsSummary(results FeedbackResults) ([]string, []summaryRow) {
+	orgs := results.GroupBy(func(r *FeedbackResult) []string {
+		return []string{dmarc.OrgDomain(r.FromDomain())}
+	})
+	// results and applied policies of every organization and domain
+	members := make(map[string]FeedbackResults)
+	applied := make(map[string]map[string]int)
+	for _, r := range results {
+		from := normalizeName(r.FromDomain())
+		members[dmarc.OrgDomain(from)] = append(members[dmarc.OrgDomain(from)], r)
+		for _, k := range []string{dmarc.OrgDomain(from), from} {
+			if applied[k] == nil {
+				applied[k] = make(map[string]int)
+			}
+			applied[k][r.AppliedTag()+"="+r.AppliedPolicy()] += r.Count
+		}
+	}
+
+	rows := make([]summaryRow, 0, len(orgs))
+	for _, o := range orgs {
+		domains := members[o.Key].GroupBy(func(r *FeedbackResult) []string {
+			return []string{normalizeName(r.FromDomain())}
+		})
+		children := make([]summaryRow, 0, len(domains))
+		for _, d := range domains {
+			cells := append([]string{dmarc.DisplayDomain(d.Key), strings.Join(topKeys(applied[d.Key]), ", "), ""}, statsCells(&d.stats)...)
+			children = append(children, summaryRow{cells: cells, query: queryTerm("header_from", d.Key)})
+		}
+		cells := append([]string{dmarc.DisplayDomain(o.Key), strings.Join(topKeys(applied[o.Key]), ", "), strconv.Itoa(len(domains))}, statsCells(&o.stats)...)
+		rows = append(rows, summaryRow{cells: cells, query: queryTerm("org_domain", o.Key), children: children})
+	}
+	return append([]string{"domain", "applied", "domains"}, statsColumns...), rows
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// fieldGetter returns the values of a field of a result. A field can have
+// several values (ex: DKIM signatures), a term matches if one of them does.
+type fieldGetter func(r *FeedbackResult) []string
+
+func one(s string) []string {
+	return []string{s}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// fields are the attributes of a result that can be filtered on
+var fields = map[string]fieldGetter{
+	"begin":     func(r *FeedbackResult) []string { return one(time.Time(r.Begin).Format(shortDateFormat)) },
+	"end":       func(r *FeedbackResult) []string { return one(time.Time(r.End).Format(shortDateFormat)) },
+	"reporter":  func(r *FeedbackResult) []string { return one(r.OrgName) },
+	"report_id": func(r *FeedbackResult) []string { return one(r.ReportID) },
+	"report_error": func(r *FeedbackResult) []string {
+		if r.Report == nil {
+			return nil
+		}
+		return r.Report.Errors
+	},
+	"file":        func(r *FeedbackResult) []string { return one(r.SourceFile) },
+	"source_ip":   func(r *FeedbackResult) []string { return one(r.SourceIP.String()) },
+	"source":      func(r *FeedbackResult) []string { return one(r.Source) },
+	"service":     func(r *FeedbackResult) []string { return one(r.ServiceName()) },
+	"sender":      func(r *FeedbackResult) []string { return one(r.Sender()) },
+	"count":       func(r *FeedbackResult) []string { return one(strconv.Itoa(r.Count)) },
+	"envelope_to": func(r *FeedbackResult) []string { return one(r.EnvelopeTo) },
+	"header_from": func(r *FeedbackResult) []string { return one(r.HeaderFrom) },
+	"dmarc": func(r *FeedbackResult) []string {
+		if r.Pass() {
+			return one("pass")
+		}
+		return one("fail")
+	},
+	"dkim":          func(r *FeedbackResult) []string { return one(r.DKIMResult) },
+	"spf":           func(r *FeedbackResult) []string { return one(r.SPFResult) },
+	"dkim_domain":   func(r *FeedbackResult) []string { return r.DKIMDomains(false) },
+	"dkim_selector": func(r *FeedbackResult) []string { return r.DKIMSelectors() },
+	"dkim_auth_result": func(r *FeedbackResult) []string {
+		out := make([]string, 0)
+		for _, d := range r.DKIMAuth {
+			out = append(out, string(d.Result))
+		}
+		return out
+	},
+	"dkim_auth_class": func(r *FeedbackResult) []string {
+		out := make([]string, 0)
+		for _, d := range r.DKIMAuth {
+			out = append(out, d.Result.Class())
+		}
+		return out
+	},
+	"spf_domain": func(r *FeedbackResult) []string { return r.SPFDomains() },
+	"spf_auth_result": func(r *FeedbackResult) []string {
+		out := make([]string, 0)
+		for _, s := range r.SPFAuth {
+			out = append(out, string(s.Result))
+		}
+		return out
+	},
+	"spf_auth_class": func(r *FeedbackResult) []string {
+		out := make([]string, 0)
+		for _, s := range r.SPFAuth {
+			out = append(out, s.Result.Class())
+		}
+		return out
+	},
+	"failure":        func(r *FeedbackResult) []string { return one(r.Failure()) },
+	"alignment":      func(r *FeedbackResult) []string { return one(r.Alignment()) },
+	"dkim_alignment": func(r *FeedbackResult) []string { return one(r.DKIMAlignment()) },
+	"spf_alignment":  func(r *FeedbackResult) []string { return one(r.SPFAlignment()) },
+	"disposition":    func(r *FeedbackResult) []string { return one(r.Disposition) },
+	"reason": func(r *FeedbackResult) []string {
+		if len(r.Reasons) == 0 {
+			return one("")
+		}
+		return r.ReasonTypes()
+	},
+	"reason_comment": func(r *FeedbackResult) []string { return r.ReasonComments() },
+	"overridden":     func(r *FeedbackResult) []string { return one(yesNo(r.Overridden())) },
+	"pct":            func(r *FeedbackResult) []string { return one(strconv.Itoa(r.Policy.Pct)) },
+	"enforceable":    func(r *FeedbackResult) []string { return one(yesNo(r.Enforceable())) },
+	"sampled_out":    func(r *FeedbackResult) []string { return one(yesNo(r.SampledOut())) },
+	"p":              func(r *FeedbackResult) []string { return one(r.Policy.P) },
+	"org_domain":     func(r *FeedbackResult) []string { return one(dmarc.OrgDomain(r.FromDomain())) },
+	"applied_policy": func(r *FeedbackResult) []string { return one(r.AppliedTag() + "=" + r.AppliedPolicy()) },
+	"policy_domain":  func(r *FeedbackResult) []string { return one(r.Policy.Domain) },
+	"network":        func(r *FeedbackResult) []string { return one(r.Network()) },
+	"asn":            func(r *FeedbackResult) []string { return one(r.ASN().String()) },
+	"country": func(r *FeedbackResult) []string {
+		if info := r.ASN(); info != nil {
+			return one(info.Country)
+		}
+		return one("")
+	},
+	"spoofed": func(r *FeedbackResult) []string { return one(yesNo(r.Spoofed())) },
+	"forwarded": func(r *FeedbackResult) []string {
+		kind, _ := r.Forwarding()
+		if kind == "" {
+			return one("no")
+		}
+		return []string{kind, "yes"}
+	},
+	"new": func(r *FeedbackResult) []string {
+		return append(r.NewIdentities(), yesNo(r.NewSender()))
+	},
+	"listed": func(r *FeedbackResult) []string {
+		zones := r.Listings()
+		return append(zones, yesNo(len(zones) > 0))
+	},
+}
+
+// reportFields are the fields a report without record is filtered on, its
+// header_from domain being the policy domain (see Filter.MatchReport)
+var reportFields = map[string]bool{
+	"begin":         true,
+	"end":           true,
+	"reporter":      true,
+	"report_id":     true,
+	"report_error":  true,
+	"file":          true,
+	"header_from":   true,
+	"org_domain":    true,
+	"policy_domain": true,
+	"p":             true,
+	"pct":           true,
+}
+
+// domainFields hold domain names: they are filtered on their canonical
+// form (A-labels) as well as on their Unicode form, and displayed in Unicode
+var domainFields = map[string]bool{
+	"envelope_to":   true,
+	"header_from":   true,
+	"dkim_domain":   true,
+	"spf_domain":    true,
+	"org_domain":    true,
+	"policy_domain": true,
+}
+
+// values returns the values of a field, with the Unicode form of the
+// domain names
+func values(field string, r *FeedbackResult) []string {
+	v := fields[field](r)
+	if domainFields[field] {
+		v = append(v, displayDomains(v)...)
+	}
+	return v
+}
+
+// FieldNames returns the sorted names of the fields
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for n := range fields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// term is a single condition of a filter
+type term struct {
+	field    string // empty for a full-text term
+	operator string
+	value    string
+	// conditions that a single auth entry must satisfy together
+	// (ex: dkim_auth[domain=sendgrid.net,result=pass])
+	group []term
+}
+
+var termPattern = regexp.MustCompile(`^([a-z_]+)(!=|!~|>=|<=|=|~|>|<)(.*)$`)
+
+var groupPattern = regexp.MustCompile(`^([a-z_]+)\[(.*)\]$`)
+
+// compare applies the operator of the term to a single value
+func (t term) compare(v string) bool {
+	v = strings.ToLower(v)
+	switch t.operator {
+	case "=", "!=":
+		return v == t.value
+	case "~", "!~":
+		return strings.Contains(v, t.value)
+	}
+	// ordering operators: numeric if possible, lexicographic otherwise
+	// (which is fine for ISO dates)
+	c := strings.Compare(v, t.value)
+	a, errA := strconv.ParseFloat(v, 64)
+	b, errB := strconv.ParseFloat(t.value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		default:
+			c = 0
+		}
+	}
+	switch t.operator {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// newTerm builds a term, the equality against a domain using its
+// canonical form
+func newTerm(field, operator, value string, domain bool) term {
+	value = strings.ToLower(value)
+	if domain && (operator == "=" || operator == "!=") {
+		value = normalizeName(value)
+	}
+	return term{field: field, operator: operator, value: value}
+}
+
+func (t term) match(r *FeedbackResult) bool {
+	if t.group != nil {
+		for _, entry := range r.authEntries(t.field) {
+			if t.matchEntry(entry) {
+				return true
+			}
+		}
+		return false
+	}
+	if t.field == "" {
+		for field := range fields {
+			for _, v := range values(field, r) {
+				if strings.Contains(strings.ToLower(v), t.value) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	negated := strings.HasPrefix(t.operator, "!")
+	for _, v := range values(t.field, r) {
+		if t.compare(v) {
+			return !negated
+		}
+	}
+	return negated
+}
+
+// matchReport is match restricted to the reportFields
+func (t term) matchReport(r *FeedbackResult) bool {
+	switch {
+	case t.group != nil:
+		return false
+	case t.field == "":
+		for field := range reportFields {
+			for _, v := range values(field, r) {
+				if strings.Contains(strings.ToLower(v), t.value) {
+					return true
+				}
+			}
+		}
+		return false
+	case !reportFields[t.field]:
+		return false
+	}
+	return t.match(r)
+}
+
+// matchEntry tells whether an auth entry satisfies every condition of the
+// group
+func (t term) matchEntry(entry map[string]string) bool {
+	for _, sub := range t.group {
+		ok := sub.compare(entry[sub.field])
+		if sub.field == "domain" {
+			ok = ok || sub.compare(dmarc.DisplayDomain(entry[sub.field]))
+		}
+		if strings.HasPrefix(sub.operator, "!") {
+			ok = !ok
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGroup parses the conditions of a group term (comma-separated)
+func parseGroup(group, conditions string) (term, error) {
+	t := term{field: group, group: make([]term, 0)}
+	for _, c := range strings.Split(conditions, ",") {
+		m := termPattern.FindStringSubmatch(strings.TrimSpace(c))
+		if m == nil {
+			return t, fmt.Errorf("invalid condition %q in %s", c, group)
+		}
+		known := false
+		for _, f := range authFields[group] {
+			known = known || f == m[1]
+		}
+		if !known {
+			return t, fmt.Errorf("unknown field %q in %s", m[1], group)
+		}
+		t.group = append(t.group, newTerm(m[1], m[2], m[3], m[1] == "domain"))
+	}
+	return t, nil
+}
+
+// Filter is a conjunction of terms, parsed from a query like
+// `header_from=example.com dkim=fail count>10 listed=yes`. The operators
+// are = != ~ (contains) !~ > >= < <=, a word without operator looks for
+// the text in every field. The auth results can be filtered signature per
+// signature, like `dkim_auth[domain=sendgrid.net,result=pass]`.
+type Filter struct {
+	Query string
+	terms []term
+}
+
+// tokenize splits the query on whitespaces, honouring double quotes
+func tokenize(query string) ([]string, error) {
+	tokens := make([]string, 0)
+	var b strings.Builder
+	quoted := false
+	for _, c := range query {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ' ' || c == '\t'):
+			if b.Len() > 0 {
+				tokens = append(tokens, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if b.Len() > 0 {
+		tokens = append(tokens, b.String())
+	}
+	return tokens, nil
+}
+
+// ParseFilter parses a query (an empty query matches everything)
+func ParseFilter(query string) (*Filter, error) {
+	f := &Filter{Query: strings.TrimSpace(query)}
+	tokens, err := tokenize(f.Query)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if g := groupPattern.FindStringSubmatch(token); g != nil {
+			if _, exists := authFields[g[1]]; !exists {
+				return nil, fmt.Errorf("unknown group %q", g[1])
+			}
+			t, err := parseGroup(g[1], g[2])
+			if err != nil {
+				return nil, err
+			}
+			f.terms = append(f.terms, t)
+			continue
+		}
+		m := termPattern.FindStringSubmatch(token)
+		if m == nil {
+			f.terms = append(f.terms, term{value: strings.ToLower(token)})
+			continue
+		}
+		if _, exists := fields[m[1]]; !exists {
+			return nil, fmt.Errorf("unknown field %q", m[1])
+		}
+		f.terms = append(f.terms, newTerm(m[1], m[2], m[3], domainFields[m[1]]))
+	}
+	return f, nil
+}
+
+// Match tells whether the result satisfies every term of the filter
+func (f *Filter) Match(r *FeedbackResult) bool {
+	if f == nil {
+		return true
+	}
+	for _, t := range f.terms {
+		if !t.match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchReport tells whether a report without record satisfies every term
+// of the filter, the terms on the fields of the records never matching it
+func (f *Filter) MatchReport(report *dmarc.Report) bool {
+	if f == nil {
+		return true
+	}
+	r := &FeedbackResult{
+		Record:     &dmarc.Record{HeaderFrom: report.Policy.Domain, Policy: report.Policy},
+		Report:     report,
+		SourceFile: report.File,
+		OrgName:    report.OrgName,
+		ReportID:   report.ReportID,
+		Begin:      Date(report.Begin),
+		End:        Date(report.End),
+	}
+	for _, t := range f.terms {
+		if !t.matchReport(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the results satisfying the filter
+func (r FeedbackResults) Filter(f *Filter) FeedbackResults {
+	if f == nil || len(f.terms) == 0 {
+		return r
+	}
+	out := make(FeedbackResults, 0)
+	for _, x := range r {
+		if f.Match(x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// authEntries are the signatures (dkim_auth) or checks (spf_auth) of a
+// result as field/value maps, to filter on several attributes of the
+// same signature
+func (r *FeedbackResult) authEntries(group string) []map[string]string {
+	out := make([]map[string]string, 0)
+	switch group {
+	case "dkim_auth":
+		for _, d := range r.DKIMAuth {
+			out = append(out, map[string]string{
+				"domain":       d.Domain,
+				"selector":     d.Selector,
+				"result":       string(d.Result),
+				"class":        d.Result.Class(),
+				"raw_result":   d.RawResult,
+				"human_result": d.HumanResult,
+			})
+		}
+	case "spf_auth":
+		for _, s := range r.SPFAuth {
+			out = append(out, map[string]string{
+				"domain":     s.Domain,
+				"result":     string(s.Result),
+				"class":      s.Result.Class(),
+				"raw_result": s.RawResult,
+			})
+		}
+	}
+	return out
+}
+
+// authFields are the attributes of the auth entries
+var authFields = map[string][]string{
+	"dkim_auth": {"domain", "selector", "result", "class", "raw_result", "human_result"},
+	"spf_auth":  {"domain", "result", "class", "raw_result"},
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// kinds of indirect traffic
+const (
+	forwardedTraffic   = "forwarded"
+	mailingListTraffic = "mailing_list"
+)
+
+// forwarderDomains are the domains of well-known forwarding services,
+// matched against the reverse DNS name and the domains that passed SPF or
+// DKIM (suffix match)
+var forwarderDomains = []string{
+	"icloud.com",
+	"me.com",
+	"pobox.com",
+	"forwardemail.net",
+	"improvmx.com",
+	"simplelogin.co",
+	"duck.com",
+	"anonaddy.me",
+}
+
+// microsoftOutbound are the domains of the Microsoft 365 outbound servers,
+// which forward the messages of their users as well as send theirs
+var microsoftOutbound = []string{"outbound.protection.outlook.com"}
+
+// listDomains are the domains of mailing list hosts, matched like
+// forwarderDomains
+var listDomains = []string{
+	"googlegroups.com",
+	"groups.io",
+	"freelists.org",
+	"lists.sourceforge.net",
+	"listserv.net",
+}
+
+// listLabels are the labels naming list servers (lists.example.org,
+// mailman.example.org...)
+var listLabels = map[string]bool{
+	"lists":    true,
+	"list":     true,
+	"listserv": true,
+	"mailman":  true,
+	"sympa":    true,
+	"lyris":    true,
+}
+
+// matchDomain tells whether name is one of the domains or a subdomain
+func matchDomain(name string, domains []string) bool {
+	name = normalizeName(name)
+	for _, d := range domains {
+		if name != "" && hasDomainSuffix(name, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// passedDomains returns the domains that passed SPF (the envelope) or DKIM
+func (r *FeedbackResult) passedDomains() []string {
+	out := r.DKIMDomains(true)
+	for _, s := range r.SPFAuth {
+		if s.Result == dmarc.ResultPass {
+			out = append(out, s.Domain)
+		}
+	}
+	return out
+}
+
+// listServer tells whether a name has a list server label below its
+// organizational domain
+func listServer(name string) bool {
+	name = normalizeName(name)
+	host := strings.TrimSuffix(name, dmarc.OrgDomain(name))
+	for _, label := range strings.Split(host, ".") {
+		if listLabels[label] {
+			return true
+		}
+	}
+	return false
+}
+
+// academicRelay tells whether a name belongs to a university (edu, ac.uk,
+// edu.au...), whose relays commonly forward the mail of alumni and staff
+func academicRelay(name string) bool {
+	org := dmarc.OrgDomain(normalizeName(name))
+	i := strings.Index(org, ".")
+	if i < 0 {
+		return false
+	}
+	suffix := org[i+1:]
+	return suffix == "edu" || strings.HasPrefix(suffix, "edu.") || strings.HasPrefix(suffix, "ac.")
+}
+
+// microsoftForwarding tells whether Microsoft 365 forwarded the messages:
+// SPF passed for the envelope of the forwarding tenant (unaligned) while
+// the signature of the domain is still there, intact or broken
+func (r *FeedbackResult) microsoftForwarding() bool {
+	if !matchDomain(r.Source, microsoftOutbound) || r.SPFAlignment() != dmarc.UnalignedPass {
+		return false
+	}
+	for _, d := range r.DKIMAuth {
+		if dmarc.Aligned(d.Domain, r.FromDomain(), r.Policy.Normalized().ADKIM) {
+			return true
+		}
+	}
+	return false
+}
+
+// Forwarding guesses whether the messages went through a forwarder or a
+// mailing list instead of coming from the sender directly, and why. kind
+// is forwardedTraffic, mailingListTraffic or "" (direct traffic). Sources
+// that passed SPF for an aligned domain are direct whatever their name.
+func (r *FeedbackResult) Forwarding() (kind, why string) {
+	for _, x := range r.Reasons {
+		switch x.Type {
+		case "mailing_list":
+			return mailingListTraffic, "override reason " + x.Type
+		case "forwarded", "trusted_forwarder":
+			return forwardedTraffic, "override reason " + x.Type
+		}
+	}
+	if r.SPFAlignment() == dmarc.AlignedPass {
+		return "", ""
+	}
+
+	// mailing lists: list hosts, or the list re-signing the messages
+	if r.Source != "" && (listServer(r.Source) || matchDomain(r.Source, listDomains)) {
+		return mailingListTraffic, "list server " + r.Source
+	}
+	for _, d := range r.passedDomains() {
+		if listServer(d) || matchDomain(d, listDomains) {
+			return mailingListTraffic, "list domain " + dmarc.DisplayDomain(d)
+		}
+	}
+	broken := ""
+	for _, d := range r.DKIMAuth {
+		if d.Result == dmarc.ResultFail && dmarc.Aligned(d.Domain, r.FromDomain(), r.Policy.Normalized().ADKIM) {
+			broken = d.Domain
+		}
+	}
+	if broken != "" && r.DKIMAlignment() == dmarc.UnalignedPass {
+		return mailingListTraffic, fmt.Sprintf("signature of %s broken and re-signed", dmarc.DisplayDomain(broken))
+	}
+
+	// forwarders, catalogued or not
+	if matchDomain(r.Source, forwarderDomains) {
+		return forwardedTraffic, "forwarder " + r.Source
+	}
+	for _, d := range r.passedDomains() {
+		if matchDomain(d, forwarderDomains) {
+			return forwardedTraffic, "forwarder domain " + dmarc.DisplayDomain(d)
+		}
+	}
+	if r.microsoftForwarding() {
+		return forwardedTraffic, "Microsoft 365 forwarding from " + r.Source
+	}
+	// catalogued services are more likely senders whose SPF is wrong
+	if r.Service != "" {
+		return "", ""
+	}
+	if r.Source != "" && academicRelay(r.Source) {
+		return forwardedTraffic, "university relay " + r.Source
+	}
+	// the original signature survived while the source is not allowed to
+	// send for the domain
+	if r.DKIMAlignment() == dmarc.AlignedPass && r.SPFAlignment() == dmarc.AuthFail {
+		return forwardedTraffic, "aligned DKIM passed, SPF failed"
+	}
+	return "", ""
+}
+
+// Forwarded tells whether the messages are likely forwarded or sent
+// through a mailing list (see Forwarding)
+func (r *FeedbackResult) Forwarded() bool {
+	kind, _ := r.Forwarding()
+	return kind != ""
+}
+
+// directResults returns the results that were not forwarded
+func directResults(results FeedbackResults) FeedbackResults {
+	out := make(FeedbackResults, 0)
+	for _, r := range results {
+		if !r.Forwarded() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// forwardingDetail describes the forwarding of a record in the detail pane
+func forwardingDetail(r *FeedbackResult) string {
+	kind, why := r.Forwarding()
+	if kind == "" {
+		return ""
+	}
+	return fmt.Sprintf("Likely %s traffic: %s\n", strings.ReplaceAll(kind, "_", " "), why)
+}
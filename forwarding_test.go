@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestForwarding(t *testing.T) {
+	dkim := func(domain string, result dmarc.Result) *dmarc.DKIMAuth {
+		return &dmarc.DKIMAuth{Domain: domain, Result: result}
+	}
+	spf := func(domain string, result dmarc.Result) []*dmarc.SPFAuth {
+		return []*dmarc.SPFAuth{{Domain: domain, Result: result}}
+	}
+	tests := []struct {
+		name    string
+		source  string
+		service string
+		reason  string
+		dkim    []*dmarc.DKIMAuth
+		spf     []*dmarc.SPFAuth
+		want    string
+	}{
+		{name: "override mailing list", reason: "mailing_list", spf: spf("example.com", dmarc.ResultPass), want: mailingListTraffic},
+		{name: "override forwarded", reason: "forwarded", want: forwardedTraffic},
+		{name: "override trusted forwarder", reason: "trusted_forwarder", want: forwardedTraffic},
+		{name: "aligned spf", source: "lists.example.org", spf: spf("example.com", dmarc.ResultPass)},
+		{name: "list label", source: "mailman.example.org", spf: spf("example.org", dmarc.ResultPass), want: mailingListTraffic},
+		{name: "list host", source: "mail-yw1-f1.google.com", dkim: []*dmarc.DKIMAuth{dkim("googlegroups.com", dmarc.ResultPass)}, want: mailingListTraffic},
+		{name: "no list label", source: "smtp.listed.example.org", spf: spf("example.org", dmarc.ResultPass)},
+		{
+			name:   "re-signed",
+			source: "mx.example.org",
+			dkim:   []*dmarc.DKIMAuth{dkim("example.com", dmarc.ResultFail), dkim("example.org", dmarc.ResultPass)},
+			want:   mailingListTraffic,
+		},
+		{name: "forwarder rdns", source: "mx.icloud.com", spf: spf("icloud.com", dmarc.ResultPass), want: forwardedTraffic},
+		{name: "forwarder domain", source: "mx.example.org", spf: spf("srs.pobox.com", dmarc.ResultPass), want: forwardedTraffic},
+		{name: "catalogued forwarder", source: "mx.pobox.com", service: "Pobox", want: forwardedTraffic},
+		{
+			name:    "microsoft forwarding",
+			source:  "mail-db8eur05on2100.outbound.protection.outlook.com",
+			service: "Microsoft 365",
+			dkim:    []*dmarc.DKIMAuth{dkim("example.com", dmarc.ResultFail)},
+			spf:     spf("tenant.example.org", dmarc.ResultPass),
+			want:    forwardedTraffic,
+		},
+		{
+			name:    "microsoft without signature",
+			source:  "mail-db8eur05on2100.outbound.protection.outlook.com",
+			service: "Microsoft 365",
+			dkim:    []*dmarc.DKIMAuth{dkim("tenant.example.org", dmarc.ResultPass)},
+			spf:     spf("tenant.example.org", dmarc.ResultPass),
+		},
+		{
+			name:    "microsoft sending",
+			source:  "mail-db8eur05on2100.outbound.protection.outlook.com",
+			service: "Microsoft 365",
+			dkim:    []*dmarc.DKIMAuth{dkim("example.com", dmarc.ResultPass)},
+			spf:     spf("example.com", dmarc.ResultPass),
+		},
+		{name: "academic relay", source: "relay.cs.example.edu", want: forwardedTraffic},
+		{name: "academic relay uk", source: "mx1.example.ac.uk", want: forwardedTraffic},
+		{name: "academic service", source: "relay.cs.example.edu", service: "Campus relay"},
+		{
+			name:   "aligned dkim, spf failed",
+			source: "mx.example.org",
+			dkim:   []*dmarc.DKIMAuth{dkim("example.com", dmarc.ResultPass)},
+			spf:    spf("example.org", dmarc.ResultFail),
+			want:   forwardedTraffic,
+		},
+		{
+			name:    "aligned dkim, spf failed, service",
+			source:  "o1.sendgrid.net",
+			service: "SendGrid",
+			dkim:    []*dmarc.DKIMAuth{dkim("example.com", dmarc.ResultPass)},
+			spf:     spf("sendgrid.net", dmarc.ResultFail),
+		},
+		{name: "spoofing", source: "host.example.net", spf: spf("example.net", dmarc.ResultFail)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &FeedbackResult{Source: tt.source, Service: tt.service, Record: &dmarc.Record{
+				HeaderFrom: "example.com",
+				DKIMAuth:   tt.dkim,
+				SPFAuth:    tt.spf,
+				Policy:     dmarc.Policy{Domain: "example.com", P: "none", Pct: 100},
+			}}
+			if tt.reason != "" {
+				r.Reasons = []*dmarc.OverrideReason{{Type: tt.reason}}
+			}
+			if kind, why := r.Forwarding(); kind != tt.want {
+				t.Errorf("Forwarding() = %q (%s), want %q", kind, why, tt.want)
+			}
+		})
+	}
+}
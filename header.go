@@ -20,6 +20,9 @@ type header struct {
 	directory   string
 	files       int
 	records     int
+	view        string
+	filter      string
+	err         error
 	width       int
 	showSpinner bool
 }
@@ -34,6 +37,7 @@ func NewHeader(directory string, files int, records int) header {
 		directory:   directory,
 		files:       files,
 		records:     records,
+		view:        "records",
 		width:       80,
 		showSpinner: false,
 	}
@@ -69,9 +73,16 @@ func (h header) View() string {
 	s += baseStyle.
 		Bold(false).
 		Foreground(lipgloss.Color("240")).
-		Render(fmt.Sprintf("Directory: %s\nFiles: %d  Records: %d",
+		Render(fmt.Sprintf("Directory: %s\nFiles: %d  Records: %d  View: %s",
 			h.directory,
 			h.files,
-			h.records)) + "\n"
+			h.records,
+			h.view)) + "\n"
+	if h.filter != "" {
+		s += baseStyle.Foreground(Theme().primary).Render("Filter: "+h.filter) + "\n"
+	}
+	if h.err != nil {
+		s += baseStyle.Foreground(lipgloss.Color("9")).Render(h.err.Error()) + "\n"
+	}
 	return s
 }
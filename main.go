@@ -7,14 +7,108 @@ import (
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/situation-sh/tmarc/dmarc"
 )
 
 func main() {
 	flag.StringVar(&directory, "d", ".", "directory to scan")
 	flag.StringVar(&selectedTheme, "t", "default", fmt.Sprintf("color theme (%s)", strings.Join(ListThemes(), ", ")))
 	flag.BoolVar(&highlightXML, "p", false, "enable xml syntax highlighting (experimental)")
+	flag.StringVar(&catalogFile, "c", "", "additional catalog of email services (yaml or json)")
+	flag.StringVar(&dnsServer, "r", "", "DNS server used for live checks (host[:port], system resolver by default)")
+	flag.BoolVar(&offline, "n", false, "offline mode: no DNS lookups (reverse DNS, SPF, DMARC and DKIM records)")
+	flag.StringVar(&dnsblZones, "dnsbl", "", "comma-separated IP blocklists to check the sources against (DNS zones or rbldnsd zone files)")
+	flag.StringVar(&rhsblZones, "rhsbl", "", "comma-separated domain blocklists to check the reverse DNS of the sources against (DNS zones or rbldnsd zone files)")
+	flag.IntVar(&prefix4, "prefix4", prefix4, "prefix length used to group the IPv4 sources")
+	flag.IntVar(&prefix6, "prefix6", prefix6, "prefix length used to group the IPv6 sources")
+	flag.StringVar(&asnFile, "asn", "", "ip2asn (iptoasn.com) or prefix2as file to group the sources by announced prefix")
+	flag.StringVar(&suffixFile, "psl", "", "additional public suffix rules (publicsuffix.org format), added to the embedded list")
+	flag.BoolVar(&replaceSuffixes, "psl-replace", false, "use the -psl file instead of the embedded public suffix list (e.g. a more recent list)")
+	flag.IntVar(&readinessWindow, "window", readinessWindow, "days of reports the policy readiness is evaluated on")
+	flag.Float64Var(&meaningfulShare, "min-share", meaningfulShare, "share of the messages of a domain (%) from which a failing sender blocks a stricter policy")
+	flag.IntVar(&newWindow, "new", newWindow, "days before the last report in which the senders first seen are flagged as new")
+	flag.Float64Var(&alertScore, "alert-score", alertScore, "robust z-score from which a daily volume is an anomaly")
+	flag.StringVar(&alertsSince, "since", "", "day (YYYY-MM-DD) from which the alerts command prints the anomalies")
+	flag.BoolVar(&jsonOutput, "json", false, "JSON output for the commands")
+	flag.StringVar(&recordColumns, "columns", "", fmt.Sprintf("comma-separated columns of the records (default %s)", strings.Join(columns, ",")))
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command [query]]\n\nCommands:\n", os.Args[0])
+		for _, c := range commands {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %-10s %s\n", c.name, c.usage)
+		}
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	resolver = NewResolver(dnsServer)
+	for _, zones := range []struct {
+		list   string
+		domain bool
+	}{{dnsblZones, false}, {rhsblZones, true}} {
+		for _, zone := range strings.Split(zones.list, ",") {
+			if zone = strings.TrimSpace(zone); zone == "" {
+				continue
+			}
+			b, err := NewBlocklist(zone, zones.domain)
+			if err != nil {
+				fmt.Printf("Cannot load the blocklist %s: %v\n", zone, err)
+				os.Exit(1)
+			}
+			blocklists = append(blocklists, b)
+		}
+	}
+	if asnFile != "" {
+		table, err := LoadASNTable(asnFile)
+		if err != nil {
+			fmt.Printf("Cannot load the ASN data %s: %v\n", asnFile, err)
+			os.Exit(1)
+		}
+		asnTable = table
+	}
+	if prefix4 < 0 || prefix4 > 32 || prefix6 < 0 || prefix6 > 128 {
+		fmt.Println("Invalid prefix length")
+		os.Exit(1)
+	}
+	if recordColumns != "" {
+		columns = make([]string, 0)
+		for _, c := range strings.Split(recordColumns, ",") {
+			c = strings.TrimSpace(c)
+			if _, exists := fields[c]; !exists {
+				fmt.Printf("Unknown column %s (available: %s)\n", c, strings.Join(FieldNames(), ", "))
+				os.Exit(1)
+			}
+			columns = append(columns, c)
+		}
+	} else if len(blocklists) > 0 {
+		columns = append(columns, "listed")
+	}
+
+	if catalogFile != "" {
+		userCatalog, err := LoadCatalog(catalogFile)
+		if err != nil {
+			fmt.Printf("Cannot load the catalog %s: %v\n", catalogFile, err)
+			os.Exit(1)
+		}
+		catalog.Extend(userCatalog)
+	}
+	if suffixFile != "" {
+		suffixes, err := dmarc.LoadSuffixList(suffixFile)
+		if err != nil {
+			fmt.Printf("Cannot load the public suffix list %s: %v\n", suffixFile, err)
+			os.Exit(1)
+		}
+		if replaceSuffixes {
+			dmarc.Suffixes = suffixes
+		} else {
+			dmarc.Suffixes.Extend(suffixes)
+		}
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
 	m := NewModel(directory)
 	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// default prefix lengths used to group the sources
+var prefix4 = 24
+var prefix6 = 48
+
+// ASNInfo describes the announced prefix an IP belongs to
+type ASNInfo struct {
+	// announced prefix (CIDR, or range when it is not a single CIDR)
+	Prefix  string
+	ASN     int
+	Country string
+	Name    string
+}
+
+func (a *ASNInfo) String() string {
+	if a == nil {
+		return ""
+	}
+	if a.ASN == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("AS%d", a.ASN)
+}
+
+// asnRange is an entry of an ip2asn (iptoasn.com) table
+type asnRange struct {
+	start, end net.IP
+	info       *ASNInfo
+}
+
+// ASNTable maps IPs to their announced prefix. It is loaded from an
+// iptoasn.com TSV file (range_start range_end AS_number country_code
+// AS_description) or from a CAIDA prefix2as file (prefix length ASN).
+type ASNTable struct {
+	ranges []asnRange
+	// prefix2as entries per prefix length
+	prefixes map[int]map[string]*ASNInfo
+	lengths  []int
+}
+
+// LoadASNTable reads an ip2asn or prefix2as file (format guessed from the
+// number of columns)
+func LoadASNTable(path string) (*ASNTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	t := &ASNTable{prefixes: make(map[int]map[string]*ASNInfo)}
+	scanner := bufio.NewScanner(file)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) < 3 {
+			cols = strings.Fields(line)
+		}
+		switch {
+		case len(cols) >= 5:
+			start, end := net.ParseIP(cols[0]), net.ParseIP(cols[1])
+			asn, err := strconv.Atoi(cols[2])
+			if start == nil || end == nil || err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid ip2asn entry", path, n)
+			}
+			info := &ASNInfo{
+				Prefix:  rangePrefix(start, end),
+				ASN:     asn,
+				Country: cols[3],
+				Name:    strings.Join(cols[4:], " "),
+			}
+			t.ranges = append(t.ranges, asnRange{start: start.To16(), end: end.To16(), info: info})
+		case len(cols) == 3:
+			length, err := strconv.Atoi(cols[1])
+			_, network, err2 := net.ParseCIDR(cols[0] + "/" + cols[1])
+			if err != nil || err2 != nil {
+				return nil, fmt.Errorf("%s:%d: invalid prefix2as entry", path, n)
+			}
+			// multi-origin prefixes are written 13335_209242, keep the first
+			origins := strings.FieldsFunc(cols[2], func(r rune) bool {
+				return r == '_' || r == ','
+			})
+			if len(origins) == 0 {
+				return nil, fmt.Errorf("%s:%d: invalid prefix2as entry", path, n)
+			}
+			asn, _ := strconv.Atoi(origins[0])
+			// the lookup masks the 16-byte form of the IPs
+			if network.IP.To4() != nil {
+				length += 96
+			}
+			if t.prefixes[length] == nil {
+				t.prefixes[length] = make(map[string]*ASNInfo)
+				t.lengths = append(t.lengths, length)
+			}
+			t.prefixes[length][network.IP.To16().String()] = &ASNInfo{Prefix: network.String(), ASN: asn}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown format", path, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(t.ranges, func(i, j int) bool {
+		return bytes.Compare(t.ranges[i].start, t.ranges[j].start) < 0
+	})
+	// longest prefixes first
+	sort.Sort(sort.Reverse(sort.IntSlice(t.lengths)))
+	return t, nil
+}
+
+// rangePrefix returns the CIDR of the range if it is exactly one,
+// otherwise "start-end"
+func rangePrefix(start, end net.IP) string {
+	s, e := start.To16(), end.To16()
+	if start.To4() != nil {
+		s, e = start.To4(), end.To4()
+	}
+	bits := len(s) * 8
+	for ones := 0; ones <= bits; ones++ {
+		mask := net.CIDRMask(ones, bits)
+		if !s.Mask(mask).Equal(s) {
+			continue
+		}
+		last := make(net.IP, len(s))
+		for i := range s {
+			last[i] = s[i] | ^mask[i]
+		}
+		if last.Equal(e) {
+			return (&net.IPNet{IP: s, Mask: mask}).String()
+		}
+	}
+	return start.String() + "-" + end.String()
+}
+
+// Lookup returns the announced prefix of an IP (nil if unknown)
+func (t *ASNTable) Lookup(ip net.IP) *ASNInfo {
+	if t == nil || ip == nil {
+		return nil
+	}
+	ip16 := ip.To16()
+	for _, length := range t.lengths {
+		key := ip16.Mask(net.CIDRMask(length, 128)).String()
+		if info, exists := t.prefixes[length][key]; exists {
+			return info
+		}
+	}
+	// last range starting before the IP
+	i := sort.Search(len(t.ranges), func(i int) bool {
+		return bytes.Compare(t.ranges[i].start, ip16) > 0
+	}) - 1
+	if i >= 0 && bytes.Compare(ip16, t.ranges[i].end) <= 0 {
+		return t.ranges[i].info
+	}
+	return nil
+}
+
+var asnTable *ASNTable
+
+// ASN returns the announced prefix of the source (nil without ASN data)
+func (r *FeedbackResult) ASN() *ASNInfo {
+	return asnTable.Lookup(r.SourceIP)
+}
+
+// Network returns the group of the source IP: its announced prefix when
+// ASN data is available, its /prefix4 (or /prefix6) otherwise
+func (r *FeedbackResult) Network() string {
+	if r.SourceIP == nil {
+		return ""
+	}
+	if info := r.ASN(); info != nil {
+		return info.Prefix
+	}
+	if ip4 := r.SourceIP.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(prefix4, 32)), Mask: net.CIDRMask(prefix4, 32)}).String()
+	}
+	return (&net.IPNet{IP: r.SourceIP.Mask(net.CIDRMask(prefix6, 128)), Mask: net.CIDRMask(prefix6, 128)}).String()
+}
+
+// networksSummary groups the sources by network, every network expands
+// into its source IPs
+func networksSummary(results FeedbackResults) ([]string, []summaryRow) {
+	groups := results.GroupBy(func(r *FeedbackResult) []string {
+		return []string{r.Network()}
+	})
+	// source IPs of every network
+	ips := make(map[string]FeedbackResults)
+	asn := make(map[string]*ASNInfo)
+	for _, r := range results {
+		n := r.Network()
+		ips[n] = append(ips[n], r)
+		asn[n] = r.ASN()
+	}
+
+	rows := make([]summaryRow, 0, len(groups))
+	for _, g := range groups {
+		children := make([]summaryRow, 0)
+		sources := ips[g.Key].GroupBy(func(r *FeedbackResult) []string {
+			return []string{r.SourceIP.String()}
+		})
+		names := make(map[string]string)
+		for _, r := range ips[g.Key] {
+			names[r.SourceIP.String()] = r.ServiceName()
+		}
+		for _, s := range sources {
+			cells := append([]string{s.Key, "", "", names[s.Key]}, statsCells(&s.stats)...)
+			children = append(children, summaryRow{cells: cells, query: queryTerm("source_ip", s.Key)})
+		}
+		cells := []string{g.Key, asn[g.Key].String(), strconv.Itoa(len(sources)), ""}
+		if info := asn[g.Key]; info != nil {
+			cells[3] = info.Name
+		} else if len(sources) > 0 {
+			// the main sender of the network
+			cells[3] = names[sources[0].Key]
+		}
+		cells = append(cells, statsCells(&g.stats)...)
+		rows = append(rows, summaryRow{
+			cells:    cells,
+			query:    queryTerm("network", g.Key),
+			children: children,
+		})
+	}
+	return append([]string{"network", "asn", "ips", "name"}, statsColumns...), rows
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeASNFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "asn.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrefix2AS(t *testing.T) {
+	table, err := LoadASNTable(writeASNFile(t, "1.2.3.0\t24\t13335\n2001:db8::\t32\t64500_64501\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip     string
+		prefix string
+		asn    int
+	}{
+		{"1.2.3.4", "1.2.3.0/24", 13335},
+		{"1.2.3.255", "1.2.3.0/24", 13335},
+		{"2001:db8:1::25", "2001:db8::/32", 64500},
+		{"1.2.4.1", "", 0},
+		{"2001:db9::1", "", 0},
+	}
+	for _, tt := range tests {
+		info := table.Lookup(net.ParseIP(tt.ip))
+		switch {
+		case tt.prefix == "" && info != nil:
+			t.Errorf("Lookup(%s) = %s, want nothing", tt.ip, info.Prefix)
+		case tt.prefix != "" && info == nil:
+			t.Errorf("Lookup(%s) = nothing, want %s", tt.ip, tt.prefix)
+		case info != nil && (info.Prefix != tt.prefix || info.ASN != tt.asn):
+			t.Errorf("Lookup(%s) = %s AS%d, want %s AS%d", tt.ip, info.Prefix, info.ASN, tt.prefix, tt.asn)
+		}
+	}
+}
+
+func TestIP2ASN(t *testing.T) {
+	table, err := LoadASNTable(writeASNFile(t, "1.2.3.0\t1.2.3.255\t13335\tUS\tCLOUDFLARENET\n5.6.0.0\t5.6.2.255\t64500\tFR\tEXAMPLE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := table.Lookup(net.ParseIP("1.2.3.9")); info == nil || info.Prefix != "1.2.3.0/24" || info.Country != "US" {
+		t.Errorf("Lookup(1.2.3.9) = %+v", info)
+	}
+	if info := table.Lookup(net.ParseIP("5.6.2.1")); info == nil || info.Prefix != "5.6.0.0-5.6.2.255" {
+		t.Errorf("Lookup(5.6.2.1) = %+v", info)
+	}
+	if info := table.Lookup(net.ParseIP("5.6.3.1")); info != nil {
+		t.Errorf("Lookup(5.6.3.1) = %+v", info)
+	}
+}
+
+func TestLoadASNTableErrors(t *testing.T) {
+	for _, content := range []string{
+		"1.2.3.0\t24\t\n",
+		"1.2.3.0\t24\t_\n",
+		"1.2.3.0\t33\t13335\n",
+		"1.2.3.0\n",
+	} {
+		if _, err := LoadASNTable(writeASNFile(t, content)); err == nil {
+			t.Errorf("LoadASNTable(%q): no error", content)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// identityKinds are the identities whose first appearance is tracked: the
+// sender (see Sender), its IP, network, reverse DNS domain and service, and
+// the header_from domain
+var identityKinds = []string{"sender", "ip", "network", "rdns", "service", "domain"}
+
+// identities returns the identities of the result, by kind
+func (r *FeedbackResult) identities() map[string]string {
+	ids := map[string]string{
+		"sender":  r.Sender(),
+		"ip":      r.SourceIP.String(),
+		"network": r.Network(),
+		"service": r.Service,
+		"domain":  r.HeaderFrom,
+	}
+	if r.Source != "" {
+		ids["rdns"] = dmarc.OrgDomain(r.Source)
+	}
+	return ids
+}
+
+// firstSeenIndex is the first day every identity was reported
+type firstSeenIndex struct {
+	first map[string]time.Time
+	// first day of the reports, and first day of the window the new
+	// identities are flagged in
+	start, cutoff time.Time
+}
+
+// indexFirstSeen indexes when every identity of the results was first
+// seen, the window being the newWindow days before the last report, and
+// attaches the index to the results. It must be given every scanned result,
+// not the filtered ones.
+func indexFirstSeen(all FeedbackResults) {
+	index := &firstSeenIndex{first: make(map[string]time.Time)}
+	var s seen
+	for _, r := range all {
+		s.Add(r)
+		day := truncateDay(time.Time(r.Begin), false)
+		for kind, id := range r.identities() {
+			if id == "" {
+				continue
+			}
+			k := kind + ":" + id
+			if first, exists := index.first[k]; !exists || day.Before(first) {
+				index.first[k] = day
+			}
+		}
+	}
+	index.start = truncateDay(s.first, false)
+	index.cutoff = truncateDay(s.last, false).AddDate(0, 0, -newWindow)
+	for _, r := range all {
+		r.firstSeen = index
+	}
+}
+
+// FirstSeen returns the first day an identity of the result was reported
+func (r *FeedbackResult) FirstSeen(kind string) time.Time {
+	if r.firstSeen == nil {
+		return time.Time{}
+	}
+	return r.firstSeen.first[kind+":"+r.identities()[kind]]
+}
+
+// NewIdentities returns the kinds of the identities of the result first
+// seen in the window. Nothing is new when there is no report before the
+// window (or when the results were not indexed, see indexFirstSeen).
+func (r *FeedbackResult) NewIdentities() []string {
+	out := make([]string, 0)
+	index := r.firstSeen
+	if index == nil || !index.cutoff.After(index.start) {
+		return out
+	}
+	ids := r.identities()
+	for _, kind := range identityKinds {
+		if ids[kind] == "" {
+			continue
+		}
+		if first := r.FirstSeen(kind); first.After(index.cutoff) {
+			out = append(out, kind)
+		}
+	}
+	return out
+}
+
+// NewSender tells whether the sender was first seen in the window
+func (r *FeedbackResult) NewSender() bool {
+	for _, kind := range r.NewIdentities() {
+		if kind == "sender" {
+			return true
+		}
+	}
+	return false
+}
+
+// newBadge marks the new senders in the views
+func newBadge(isNew bool) string {
+	if isNew {
+		return "★ new"
+	}
+	return ""
+}
+
+// newDetail describes the new identities of a record in the detail pane
+func newDetail(r *FeedbackResult) string {
+	kinds := r.NewIdentities()
+	if len(kinds) == 0 {
+		return ""
+	}
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s %s", kind, r.identities()[kind])
+		if kind == "domain" {
+			parts[i] = "domain " + domainLabel(r.HeaderFrom)
+		}
+	}
+	return fmt.Sprintf("New since %s: %s\n", r.firstSeen.cutoff.AddDate(0, 0, 1).Format(shortDateFormat), strings.Join(parts, ", "))
+}
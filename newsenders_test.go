@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestNewIdentities(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	result := func(days int, ip, source, service, domain string) *FeedbackResult {
+		begin := day.AddDate(0, 0, days)
+		return &FeedbackResult{
+			Record: &dmarc.Record{
+				SourceIP:   net.ParseIP(ip),
+				HeaderFrom: domain,
+			},
+			Begin:   Date(begin),
+			End:     Date(begin.Add(24 * time.Hour)),
+			Source:  source,
+			Service: service,
+		}
+	}
+	old := result(0, "192.0.2.1", "", "SendGrid", "example.com")
+	sameSender := result(20, "192.0.2.200", "", "SendGrid", "example.com")
+	newIP := result(20, "198.51.100.7", "mail.example.net", "", "example.com")
+	newDomain := result(21, "192.0.2.1", "", "SendGrid", "new.example")
+
+	all := FeedbackResults{old, sameSender, newIP, newDomain}
+	indexFirstSeen(all)
+
+	tests := []struct {
+		name string
+		r    *FeedbackResult
+		want []string
+	}{
+		{"before the window", old, []string{}},
+		{"new IP of a known sender", sameSender, []string{"ip"}},
+		{"new source", newIP, []string{"sender", "ip", "network", "rdns"}},
+		{"new domain", newDomain, []string{"domain"}},
+	}
+	for _, tt := range tests {
+		if got := tt.r.NewIdentities(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !newIP.NewSender() || sameSender.NewSender() {
+		t.Error("only the source first seen in the window is a new sender")
+	}
+	if got := sameSender.FirstSeen("sender"); !got.Equal(day) {
+		t.Errorf("sender first seen %s, want %s", got, day)
+	}
+
+	// the index is the one of the results it was built from
+	alone := result(20, "203.0.113.9", "", "", "example.com")
+	indexFirstSeen(FeedbackResults{alone})
+	if got := alone.NewIdentities(); len(got) != 0 {
+		t.Errorf("nothing is new without report before the window, got %v", got)
+	}
+	if got := newIP.NewIdentities(); len(got) != 4 {
+		t.Errorf("indexing other results changed the new identities to %v", got)
+	}
+	if got := (&FeedbackResult{Record: old.Record}).NewIdentities(); len(got) != 0 {
+		t.Errorf("a result not indexed has no new identity, got %v", got)
+	}
+}
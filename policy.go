@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// LiveDMARC is the DMARC record currently published in DNS
+type LiveDMARC struct {
+	Policy dmarc.Policy
+	Raw    string
+	// Err is set when the record cannot be fetched or parsed
+	Err error
+	// Missing is true when the domain does not publish a record
+	Missing bool
+}
+
+func (l *LiveDMARC) String() string {
+	switch {
+	case l == nil:
+		return "…"
+	case l.Missing:
+		return "no record"
+	case l.Err != nil:
+		return "error"
+	}
+	return l.Policy.String()
+}
+
+// Short is a compact form of String for tables
+func (l *LiveDMARC) Short() string {
+	if offline {
+		return "-"
+	}
+	if l == nil || l.Missing || l.Err != nil {
+		return l.String()
+	}
+	return fmt.Sprintf("p=%s pct=%d", l.Policy.P, l.Policy.Normalized().Pct)
+}
+
+// dmarcVersion matches the version tag starting a DMARC record
+var dmarcVersion = regexp.MustCompile(`(?i)^v\s*=\s*DMARC1(\s|;|$)`)
+
+// LookupDMARC fetches and parses the record at _dmarc.<domain>
+func LookupDMARC(ctx context.Context, r Resolver, domain string) *LiveDMARC {
+	txts, err := r.LookupTXT(ctx, "_dmarc."+normalizeName(domain))
+	if err != nil {
+		if isNotFound(err) {
+			return &LiveDMARC{Missing: true}
+		}
+		return &LiveDMARC{Err: err}
+	}
+	records := make([]string, 0)
+	for _, txt := range txts {
+		if dmarcVersion.MatchString(strings.TrimSpace(txt)) {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return &LiveDMARC{Missing: true}
+	case 1:
+		policy, err := dmarc.ParseDMARCRecord(domain, records[0])
+		return &LiveDMARC{Policy: policy, Raw: records[0], Err: err}
+	default:
+		return &LiveDMARC{Err: fmt.Errorf("%d DMARC records", len(records))}
+	}
+}
+
+// liveDMARC caches the live records per domain
+var liveDMARC = struct {
+	sync.Mutex
+	records map[string]*LiveDMARC
+}{records: make(map[string]*LiveDMARC)}
+
+func getLiveDMARC(domain string) *LiveDMARC {
+	liveDMARC.Lock()
+	defer liveDMARC.Unlock()
+	return liveDMARC.records[domain]
+}
+
+// fetchLiveDMARC looks up in background the records of the policy domains
+// that are not in the cache yet
+func fetchLiveDMARC(results FeedbackResults) tea.Cmd {
+	if offline {
+		return nil
+	}
+	domains := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, r := range results {
+		d := r.Policy.Domain
+		if d == "" || seen[d] || getLiveDMARC(d) != nil {
+			continue
+		}
+		seen[d] = true
+		domains = append(domains, d)
+	}
+	if len(domains) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		var wg sync.WaitGroup
+		for _, d := range domains {
+			wg.Add(1)
+			go func(d string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+				defer cancel()
+				live := LookupDMARC(ctx, resolver, d)
+				liveDMARC.Lock()
+				liveDMARC.records[d] = live
+				liveDMARC.Unlock()
+			}(d)
+		}
+		wg.Wait()
+		return RefreshViewMsg{}
+	}
+}
+
+// policySpan is a period during which a receiver applied the same policy
+type policySpan struct {
+	Domain   string
+	Reporter string
+	Policy   dmarc.Policy
+	First    time.Time
+	Last     time.Time
+	Reports  map[string]bool
+	Messages int
+}
+
+// policyTimeline returns the spans sorted by domain, then by date
+func policyTimeline(results FeedbackResults) []*policySpan {
+	// one entry per report
+	type report struct {
+		domain, reporter, id string
+		policy               dmarc.Policy
+		begin, end           time.Time
+		messages             int
+	}
+	index := make(map[string]*report)
+	for _, r := range results {
+		if r.Policy.Domain == "" {
+			continue
+		}
+		key := r.OrgName + "|" + r.ReportID + "|" + r.Policy.Domain
+		rep, exists := index[key]
+		if !exists {
+			rep = &report{
+				domain:   r.Policy.Domain,
+				reporter: r.OrgName,
+				id:       r.ReportID,
+				policy:   r.Policy,
+				begin:    time.Time(r.Begin),
+				end:      time.Time(r.End),
+			}
+			index[key] = rep
+		}
+		rep.messages += r.Count
+	}
+	reports := make([]*report, 0, len(index))
+	for _, rep := range index {
+		reports = append(reports, rep)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if a.domain != b.domain {
+			return a.domain < b.domain
+		}
+		if a.reporter != b.reporter {
+			return a.reporter < b.reporter
+		}
+		return a.begin.Before(b.begin)
+	})
+
+	// merge the consecutive reports with the same policy
+	spans := make([]*policySpan, 0)
+	var current *policySpan
+	for _, rep := range reports {
+		if current == nil || current.Domain != rep.domain ||
+			current.Reporter != rep.reporter || !current.Policy.Equal(rep.policy) {
+			current = &policySpan{
+				Domain:   rep.domain,
+				Reporter: rep.reporter,
+				Policy:   rep.policy,
+				First:    rep.begin,
+				Reports:  make(map[string]bool),
+			}
+			spans = append(spans, current)
+		}
+		current.Last = rep.end
+		current.Reports[rep.id] = true
+		current.Messages += rep.messages
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].Domain != spans[j].Domain {
+			return spans[i].Domain < spans[j].Domain
+		}
+		return spans[i].First.Before(spans[j].First)
+	})
+	return spans
+}
+
+// referencePolicies returns the policy a receiver should apply today for
+// every domain: the live record when known, otherwise the most recent
+// policy seen by any receiver
+func referencePolicies(spans []*policySpan) map[string]dmarc.Policy {
+	latest := make(map[string]*policySpan)
+	for _, s := range spans {
+		if l, exists := latest[s.Domain]; !exists || s.Last.After(l.Last) {
+			latest[s.Domain] = s
+		}
+	}
+	out := make(map[string]dmarc.Policy)
+	for d, s := range latest {
+		out[d] = s.Policy
+		if live := getLiveDMARC(d); live != nil && !live.Missing && live.Err == nil {
+			out[d] = live.Policy
+		}
+	}
+	return out
+}
+
+// policiesSummary shows the policy applied by every receiver over time
+func policiesSummary(results FeedbackResults) ([]string, []summaryRow) {
+	spans := policyTimeline(results)
+	reference := referencePolicies(spans)
+
+	// last span of every (domain, reporter)
+	last := make(map[string]*policySpan)
+	for _, s := range spans {
+		key := s.Domain + "|" + s.Reporter
+		if l, exists := last[key]; !exists || s.Last.After(l.Last) {
+			last[key] = s
+		}
+	}
+
+	rows := make([]summaryRow, 0, len(spans))
+	for _, s := range spans {
+		live := getLiveDMARC(s.Domain)
+		status := make([]string, 0)
+		// only the current policy of a receiver matters
+		if last[s.Domain+"|"+s.Reporter] == s {
+			if !s.Policy.Equal(reference[s.Domain]) {
+				status = append(status, "outdated")
+			}
+			// a missing record (zero policy) differs as well
+			if live != nil && live.Err == nil && !live.Policy.Equal(s.Policy) {
+				status = append(status, "≠ live")
+			}
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			dmarc.DisplayDomain(s.Domain),
+			s.Reporter,
+			s.First.Format(shortDateFormat),
+			s.Last.Format(shortDateFormat),
+			s.Policy.P,
+			s.Policy.Normalized().SP,
+			strconv.Itoa(s.Policy.Normalized().Pct),
+			s.Policy.Normalized().ADKIM,
+			s.Policy.Normalized().ASPF,
+			strconv.Itoa(len(s.Reports)),
+			strconv.Itoa(s.Messages),
+			live.Short(),
+			strings.Join(status, ", "),
+		}, query: queryTerm("policy_domain", s.Domain) + " " + queryTerm("reporter", s.Reporter)})
+	}
+	return []string{
+		"domain", "reporter", "first", "last", "p", "sp", "pct", "adkim", "aspf",
+		"reports", "messages", "live", "status",
+	}, rows
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLookupDMARC(t *testing.T) {
+	dns := &stubResolver{txt: map[string][]string{
+		"_dmarc.example.com": {"v=DMARC1; p=reject; pct=0"},
+		"_dmarc.example.net": {"v=DMARC10; p=reject", "spf1"},
+		"_dmarc.example.org": {"v=DMARC1 p=none", "V=dmarc1;p=none"},
+	}}
+	if got := LookupDMARC(context.Background(), dns, "example.com"); got.Err != nil || got.Policy.P != "reject" || got.Policy.Pct != 0 {
+		t.Errorf("example.com: %+v", got)
+	}
+	if got := LookupDMARC(context.Background(), dns, "example.net"); !got.Missing {
+		t.Errorf("example.net: v=DMARC10 taken as a record (%+v)", got)
+	}
+	if got := LookupDMARC(context.Background(), dns, "example.org"); got.Err == nil {
+		t.Errorf("example.org: 2 records accepted (%+v)", got)
+	}
+	if got := LookupDMARC(context.Background(), dns, "example.info"); !got.Missing {
+		t.Errorf("example.info: %+v", got)
+	}
+}
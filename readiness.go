@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// verdicts of the readiness advisor
+const (
+	verdictReady    = "ready"
+	verdictAlmost   = "almost"
+	verdictNotReady = "not ready"
+)
+
+// share of the legitimate messages that may fail before a domain is not
+// ready for a stricter policy
+const readinessTolerance = 0.01
+
+// policySteps is the path from monitoring to a full reject policy
+var policySteps = []dmarc.Policy{
+	{P: "none", Pct: 100},
+	{P: "quarantine", Pct: 10},
+	{P: "quarantine", Pct: 25},
+	{P: "quarantine", Pct: 50},
+	{P: "quarantine", Pct: 100},
+	{P: "reject", Pct: 10},
+	{P: "reject", Pct: 25},
+	{P: "reject", Pct: 50},
+	{P: "reject", Pct: 100},
+}
+
+// senderReadiness is the traffic of a sender of a domain over the window
+type senderReadiness struct {
+	Sender   string
+	Messages int
+	// messages failing DMARC (transient errors and forwarded traffic aside)
+	Failing int
+	// the sender passed DMARC in part of its messages
+	Legitimate bool
+}
+
+// readiness tells whether a domain can move to a stricter policy, from
+// its traffic over a trailing window
+type readiness struct {
+	Domain string
+	// policy published at the end of the window (normalized)
+	Policy   dmarc.Policy
+	From, To time.Time
+	Messages int
+	// messages of the legitimate senders, and the ones failing DMARC that
+	// a stricter policy would affect
+	Legitimate int
+	Affected   int
+	// failing messages of the other senders (spoofing), that a stricter
+	// policy would stop
+	Unauthenticated int
+	// failing messages forwarded or sent through mailing lists: a stricter
+	// policy would affect them, but only the forwarders (ARC) can fix them
+	Forwarded int
+	// legitimate senders with a meaningful volume that still fail
+	Blocking []*senderReadiness
+}
+
+// Verdict tells whether the domain is ready for the next step
+func (r *readiness) Verdict() string {
+	switch {
+	case r.Affected == 0:
+		return verdictReady
+	case rate(r.Affected, r.Legitimate) <= readinessTolerance:
+		return verdictAlmost
+	}
+	return verdictNotReady
+}
+
+// step returns the position of the policy on the path to reject, a pct of
+// 0 enforcing nothing more than the step before
+func (r *readiness) step() int {
+	if r.Policy.P == "none" || r.Policy.P == "" {
+		return 0
+	}
+	for i, s := range policySteps {
+		if s.P == r.Policy.P && r.Policy.Pct == 0 {
+			return i - 1
+		}
+		if s.P == r.Policy.P && s.Pct >= r.Policy.Pct {
+			return i
+		}
+	}
+	return len(policySteps) - 1
+}
+
+// NextStep suggests the policy to publish next: one step further when
+// ready, a first quarantine step with a low pct when almost ready from
+// p=none, the current policy otherwise
+func (r *readiness) NextStep() dmarc.Policy {
+	i := r.step()
+	switch v := r.Verdict(); {
+	case v == verdictReady && i+1 < len(policySteps):
+		return policySteps[i+1]
+	case v == verdictAlmost && i == 0:
+		return policySteps[1]
+	}
+	return policySteps[i]
+}
+
+// nextStepText describes the next step
+func (r *readiness) nextStepText() string {
+	next := r.NextStep()
+	switch {
+	case next != policySteps[r.step()]:
+		return fmt.Sprintf("p=%s pct=%d", next.P, next.Pct)
+	case r.Verdict() == verdictReady:
+		return "keep p=" + next.P
+	case len(r.Blocking) == 1:
+		return "fix 1 sender"
+	case len(r.Blocking) > 1:
+		return fmt.Sprintf("fix %d senders", len(r.Blocking))
+	}
+	// failures spread over small senders
+	return "fix small senders"
+}
+
+// nextStepAffected estimates the legitimate messages the next step would
+// quarantine or reject
+func (r *readiness) nextStepAffected() float64 {
+	next := r.NextStep()
+	if next.P == "none" {
+		return 0
+	}
+	return float64(r.Affected) * float64(next.Pct) / 100
+}
+
+// legitimateSender tells whether the messages passed DMARC (aligned DKIM or
+// SPF) in some records: an unaligned pass only authenticates the domain of
+// the sender, which spoofers can own, and a known service can be abused
+func legitimateSender(results FeedbackResults) bool {
+	for _, r := range results {
+		if r.Pass() || r.Alignment() == dmarc.AlignedPass {
+			return true
+		}
+	}
+	return false
+}
+
+// domainReadiness evaluates every policy domain over the window days
+// before its last report
+func domainReadiness(results FeedbackResults, window int) []*readiness {
+	domains := make(map[string]FeedbackResults)
+	for _, r := range results {
+		domains[r.Policy.Domain] = append(domains[r.Policy.Domain], r)
+	}
+	out := make([]*readiness, 0, len(domains))
+	for domain, all := range domains {
+		rd := &readiness{Domain: domain}
+		for _, r := range all {
+			if end := time.Time(r.End); end.After(rd.To) {
+				rd.To = end
+				rd.Policy = r.Policy.Normalized()
+			}
+		}
+		rd.From = truncateDay(rd.To, false).AddDate(0, 0, -window)
+		senders := make(map[string]FeedbackResults)
+		for _, r := range all {
+			if !truncateDay(time.Time(r.End), false).After(rd.From) {
+				continue
+			}
+			rd.Messages += r.Count
+			senders[r.Sender()] = append(senders[r.Sender()], r)
+		}
+		for sender, msgs := range senders {
+			s := &senderReadiness{Sender: sender, Legitimate: legitimateSender(msgs)}
+			for _, r := range msgs {
+				s.Messages += r.Count
+				switch {
+				case r.Pass() || r.Transient():
+				case r.Forwarded():
+					rd.Forwarded += r.Count
+				default:
+					s.Failing += r.Count
+				}
+			}
+			if !s.Legitimate {
+				rd.Unauthenticated += s.Failing
+				continue
+			}
+			rd.Legitimate += s.Messages
+			rd.Affected += s.Failing
+			if s.Failing > 0 && rate(s.Messages, rd.Messages)*100 >= meaningfulShare {
+				rd.Blocking = append(rd.Blocking, s)
+			}
+		}
+		sort.Slice(rd.Blocking, func(i, j int) bool {
+			if rd.Blocking[i].Failing == rd.Blocking[j].Failing {
+				return rd.Blocking[i].Sender < rd.Blocking[j].Sender
+			}
+			return rd.Blocking[i].Failing > rd.Blocking[j].Failing
+		})
+		out = append(out, rd)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages == out[j].Messages {
+			return out[i].Domain < out[j].Domain
+		}
+		return out[i].Messages > out[j].Messages
+	})
+	return out
+}
+
+// readinessSummary gives the readiness verdict of every policy domain,
+// every domain expanding into its blocking senders
+func readinessSummary(results FeedbackResults) ([]string, []summaryRow) {
+	domains := domainReadiness(results, readinessWindow)
+	rows := make([]summaryRow, 0, len(domains))
+	for _, rd := range domains {
+		query := queryTerm("policy_domain", rd.Domain) + " end>" + rd.From.Format(shortDateFormat)
+		children := make([]summaryRow, 0, len(rd.Blocking))
+		for _, s := range rd.Blocking {
+			children = append(children, summaryRow{
+				cells: []string{s.Sender, "", "", strconv.Itoa(s.Messages), strconv.Itoa(s.Messages), strconv.Itoa(s.Failing), "", "", "", "blocking", ""},
+				query: query + " " + queryTerm("sender", s.Sender) + " dmarc=fail forwarded=no",
+			})
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			dmarc.DisplayDomain(rd.Domain),
+			fmt.Sprintf("p=%s pct=%d", rd.Policy.P, rd.Policy.Pct),
+			rd.From.AddDate(0, 0, 1).Format(shortDateFormat),
+			strconv.Itoa(rd.Messages),
+			strconv.Itoa(rd.Legitimate),
+			strconv.Itoa(rd.Affected),
+			strconv.Itoa(rd.Unauthenticated),
+			strconv.Itoa(rd.Forwarded),
+			fmt.Sprintf("%.1f", rd.nextStepAffected()),
+			rd.Verdict(),
+			rd.nextStepText(),
+		}, query: query, children: children})
+	}
+	return []string{"domain", "policy", "since", "messages", "legitimate", "affected", "unauthenticated", "forwarded", "next affected", "verdict", "next step"}, rows
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestReadinessStep(t *testing.T) {
+	tests := []struct {
+		policy dmarc.Policy
+		step   int
+		next   string
+	}{
+		{dmarc.Policy{P: "none", Pct: 100}, 0, "p=quarantine pct=10"},
+		{dmarc.Policy{P: "quarantine", Pct: 0}, 0, "p=quarantine pct=10"},
+		{dmarc.Policy{P: "quarantine", Pct: 10}, 1, "p=quarantine pct=25"},
+		{dmarc.Policy{P: "quarantine", Pct: 30}, 3, "p=quarantine pct=100"},
+		{dmarc.Policy{P: "reject", Pct: 0}, 4, "p=reject pct=10"},
+		{dmarc.Policy{P: "reject", Pct: 100}, 8, "keep p=reject"},
+	}
+	for _, tt := range tests {
+		r := &readiness{Policy: tt.policy}
+		if got := r.step(); got != tt.step {
+			t.Errorf("%s: step %d, want %d", tt.policy, got, tt.step)
+		}
+		if got := r.nextStepText(); got != tt.next {
+			t.Errorf("%s: next step %q, want %q", tt.policy, got, tt.next)
+		}
+	}
+}
+
+func TestLegitimateSender(t *testing.T) {
+	record := func(service, dkim, domain string) *FeedbackResult {
+		return &FeedbackResult{Service: service, Record: &dmarc.Record{
+			HeaderFrom: "example.com",
+			DKIMResult: dkim,
+			SPFResult:  "fail",
+			DKIMAuth:   []*dmarc.DKIMAuth{{Domain: domain, Result: dmarc.ResultPass}},
+			Policy:     dmarc.Policy{Domain: "example.com", P: "none", Pct: 100},
+		}}
+	}
+	tests := []struct {
+		name    string
+		results FeedbackResults
+		want    bool
+	}{
+		{"aligned pass", FeedbackResults{record("", "fail", "example.com"), record("", "fail", "other.test")}, true},
+		{"dmarc pass", FeedbackResults{record("", "pass", "other.test")}, true},
+		{"unaligned pass", FeedbackResults{record("", "fail", "other.test")}, false},
+		{"failing service", FeedbackResults{record("SendGrid", "fail", "sendgrid.net")}, false},
+	}
+	for _, tt := range tests {
+		if got := legitimateSender(tt.results); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// reportPeriod returns the reporting period
+func reportPeriod(r *dmarc.Report) string {
+	return r.Begin.Format(shortDateFormat) + " → " + r.End.Format(shortDateFormat)
+}
+
+// reportsWithoutRecords returns the reports that have no record (a reporter
+// may only send errors) and match the filter: no result refers to them
+func reportsWithoutRecords(reports []*dmarc.Report, f *Filter) []*dmarc.Report {
+	out := make([]*dmarc.Report, 0)
+	for _, report := range reports {
+		if len(report.Records) == 0 && f.MatchReport(report) {
+			out = append(out, report)
+		}
+	}
+	return out
+}
+
+// Reports returns the reports of the results and the reports without
+// record (see reportsWithoutRecords), the most recent first
+func (r FeedbackResults) Reports(empty []*dmarc.Report) []*dmarc.Report {
+	seen := make(map[*dmarc.Report]bool)
+	out := append([]*dmarc.Report{}, empty...)
+	for _, x := range r {
+		if x.Report == nil || seen[x.Report] {
+			continue
+		}
+		seen[x.Report] = true
+		out = append(out, x.Report)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].End.After(out[j].End)
+	})
+	return out
+}
+
+// reportDetail describes the report of a record in the detail pane
+func reportDetail(r *dmarc.Report) string {
+	if r == nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Report %s by %s", r.ReportID, r.OrgName)
+	if r.Email != "" {
+		fmt.Fprintf(&b, " <%s>", r.Email)
+	}
+	fmt.Fprintf(&b, ", %s, %d records, %d messages\n", reportPeriod(r), len(r.Records), r.Messages())
+	if r.ExtraContactInfo != "" {
+		fmt.Fprintf(&b, "  contact: %s\n", r.ExtraContactInfo)
+	}
+	for _, e := range r.Errors {
+		fmt.Fprintf(&b, "  error: %s\n", e)
+	}
+	return b.String()
+}
+
+// reportsSummary lists the reports with their metadata and totals, the
+// reports with errors expand into them
+func reportsSummary(results FeedbackResults, empty []*dmarc.Report) ([]string, []summaryRow) {
+	// stats of the displayed (filtered) records
+	index := make(map[*dmarc.Report]*stats)
+	for _, r := range results {
+		if index[r.Report] == nil {
+			index[r.Report] = &stats{}
+		}
+		index[r.Report].Add(r)
+	}
+	reports := results.Reports(empty)
+	rows := make([]summaryRow, 0, len(reports))
+	for _, report := range reports {
+		if index[report] == nil {
+			index[report] = &stats{}
+		}
+		children := make([]summaryRow, 0, len(report.Errors))
+		for _, e := range report.Errors {
+			children = append(children, summaryRow{
+				cells: []string{"error", e, "", "", "", "", "", "", ""},
+				query: queryTerm("file", report.File),
+			})
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			report.ReportID,
+			report.OrgName,
+			report.Email,
+			report.Begin.Format(shortDateFormat),
+			report.End.Format(shortDateFormat),
+			strconv.Itoa(len(report.Records)),
+			strconv.Itoa(report.Messages()),
+			index[report].DMARCRate(),
+			strconv.Itoa(len(report.Errors)),
+		}, query: queryTerm("file", report.File), children: children})
+	}
+	return []string{
+		"report_id", "reporter", "email", "begin", "end", "records", "messages", "dmarc", "errors",
+	}, rows
+}
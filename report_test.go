@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestReportsWithoutRecords(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	google := &dmarc.Report{
+		OrgName: "google.com",
+		Begin:   day,
+		End:     day.Add(24 * time.Hour),
+		Policy:  dmarc.Policy{Domain: "example.com", P: "reject", Pct: 100},
+		Errors:  []string{"temporary failure"},
+	}
+	yahoo := &dmarc.Report{
+		OrgName: "yahoo.com",
+		Begin:   day.AddDate(0, 0, 7),
+		End:     day.AddDate(0, 0, 8),
+		Policy:  dmarc.Policy{Domain: "example.org", P: "none", Pct: 100},
+	}
+	full := &dmarc.Report{
+		OrgName: "google.com",
+		Records: []*dmarc.Record{{HeaderFrom: "example.com"}},
+	}
+	reports := []*dmarc.Report{google, yahoo, full}
+
+	tests := []struct {
+		query string
+		want  []*dmarc.Report
+	}{
+		{"", []*dmarc.Report{google, yahoo}},
+		{"reporter=google.com", []*dmarc.Report{google}},
+		{"header_from=example.org", []*dmarc.Report{yahoo}},
+		{"policy_domain!=example.org", []*dmarc.Report{google}},
+		{"p=reject", []*dmarc.Report{google}},
+		{"begin>=2024-03-05", []*dmarc.Report{yahoo}},
+		{"report_error~temporary", []*dmarc.Report{google}},
+		{"yahoo", []*dmarc.Report{yahoo}},
+		// terms on the records leave them out
+		{"dkim=fail", nil},
+		{"reporter=google.com source_ip=192.0.2.1", nil},
+		{"dkim_auth[result=pass]", nil},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.query)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		got := reportsWithoutRecords(reports, f)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: %d reports, want %d", tt.query, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: got the report of %s, want %s", tt.query, got[i].OrgName, tt.want[i].OrgName)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// days a report may take to arrive after the period it covers
+const reportDelay = 2
+
+// gap is a run of days no report of a reporter covers
+type gap struct {
+	from, to time.Time
+}
+
+func (g gap) days() int {
+	return int(g.to.Sub(g.from).Hours()/24) + 1
+}
+
+// reporterCoverage is what a reporting organisation sent
+type reporterCoverage struct {
+	Name     string
+	Emails   map[string]int
+	Reports  []*dmarc.Report
+	Messages int
+	// first and last day covered by the reports
+	First, Last time.Time
+	// median days between two reports
+	Cadence float64
+	Gaps    []gap
+	// days between the last day covered by the reporter and the last day
+	// its reports should cover by now (reportDelay days ago)
+	Silent int
+}
+
+// Stopped tells whether the reports stopped arriving: the reporter has
+// been silent for more than twice its cadence (and two days at least)
+func (c *reporterCoverage) Stopped() bool {
+	return c.Silent >= 2 && float64(c.Silent) > 2*c.Cadence
+}
+
+// reportDays returns the first and last day a report covers (a report
+// ending at midnight does not cover the next day)
+func reportDays(r *dmarc.Report) (time.Time, time.Time) {
+	first := truncateDay(r.Begin, false)
+	last := truncateDay(r.End.Add(-time.Second), false)
+	if last.Before(first) {
+		last = first
+	}
+	return first, last
+}
+
+// reporterCoverages groups the reports of the results and the reports
+// without record per reporter, the biggest reporters first
+func reporterCoverages(results FeedbackResults, empty []*dmarc.Report) []*reporterCoverage {
+	index := make(map[string]*reporterCoverage)
+	for _, report := range results.Reports(empty) {
+		c := index[report.OrgName]
+		if c == nil {
+			c = &reporterCoverage{Name: report.OrgName, Emails: make(map[string]int)}
+			index[report.OrgName] = c
+		}
+		c.Reports = append(c.Reports, report)
+		c.Emails[report.Email]++
+	}
+	for _, r := range results {
+		if c := index[r.OrgName]; c != nil {
+			c.Messages += r.Count
+		}
+	}
+
+	expected := truncateDay(time.Now(), false).AddDate(0, 0, -reportDelay)
+	out := make([]*reporterCoverage, 0, len(index))
+	for _, c := range index {
+		covered := make(map[time.Time]bool)
+		begins := make([]time.Time, 0, len(c.Reports))
+		for _, report := range c.Reports {
+			first, last := reportDays(report)
+			for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+				covered[day] = true
+			}
+			if c.First.IsZero() || first.Before(c.First) {
+				c.First = first
+			}
+			if last.After(c.Last) {
+				c.Last = last
+			}
+			begins = append(begins, first)
+		}
+		// runs of days without report
+		var current *gap
+		for day := c.First; !day.After(c.Last); day = day.AddDate(0, 0, 1) {
+			switch {
+			case !covered[day] && current == nil:
+				current = &gap{from: day, to: day}
+			case !covered[day]:
+				current.to = day
+			case current != nil:
+				c.Gaps = append(c.Gaps, *current)
+				current = nil
+			}
+		}
+		sort.Slice(begins, func(i, j int) bool { return begins[i].Before(begins[j]) })
+		intervals := make([]float64, 0, len(begins))
+		for i := 1; i < len(begins); i++ {
+			if d := begins[i].Sub(begins[i-1]).Hours() / 24; d > 0 {
+				intervals = append(intervals, d)
+			}
+		}
+		c.Cadence = median(intervals)
+		if len(intervals) == 0 {
+			// a single report, daily reports are the norm
+			c.Cadence = 1
+		}
+		c.Silent = int(math.Max(0, math.Round(expected.Sub(c.Last).Hours()/24)))
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages == out[j].Messages {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Messages > out[j].Messages
+	})
+	return out
+}
+
+// reportQuery selects the latest report of the reporter whose last covered
+// day is day
+func (c *reporterCoverage) reportQuery(day time.Time) string {
+	var begin time.Time
+	for _, report := range c.Reports {
+		if first, last := reportDays(report); last.Equal(day) && first.After(begin) {
+			begin = first
+		}
+	}
+	return queryTerm("reporter", c.Name) + " begin=" + begin.Format(shortDateFormat)
+}
+
+// reportersSummary lists the reporting organisations with their coverage,
+// every reporter expanding into the gaps of its reports
+func reportersSummary(results FeedbackResults, empty []*dmarc.Report) ([]string, []summaryRow) {
+	coverages := reporterCoverages(results, empty)
+	rows := make([]summaryRow, 0, len(coverages))
+	for _, c := range coverages {
+		query := queryTerm("reporter", c.Name)
+		missing := 0
+		children := make([]summaryRow, 0, len(c.Gaps))
+		for _, g := range c.Gaps {
+			missing += g.days()
+			children = append(children, summaryRow{
+				cells: []string{"gap", "", "", g.from.Format(shortDateFormat), g.to.Format(shortDateFormat), "", "", "", strconv.Itoa(g.days()), "", ""},
+				// the report before the gap
+				query: c.reportQuery(g.from.AddDate(0, 0, -1)),
+			})
+		}
+		status := ""
+		if c.Stopped() {
+			status = "stopped"
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			c.Name,
+			strings.Join(topKeys(c.Emails), ", "),
+			strconv.Itoa(len(c.Reports)),
+			c.First.Format(shortDateFormat),
+			c.Last.Format(shortDateFormat),
+			strconv.Itoa(c.Messages),
+			fmt.Sprintf("%.0f", c.Cadence),
+			strconv.Itoa(len(c.Gaps)),
+			strconv.Itoa(missing),
+			strconv.Itoa(c.Silent),
+			status,
+		}, query: query, children: children})
+	}
+	return []string{"reporter", "email", "reports", "first", "last", "messages", "cadence", "gaps", "missing days", "silent days", "status"}, rows
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// dnsTimeout bounds every DNS based check
+const dnsTimeout = 5 * time.Second
+
+// Resolver is the subset of *net.Resolver used by tmarc. It can be
+// replaced by a stub to work offline.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// NewResolver returns the system resolver if server is empty, otherwise a
+// resolver that sends all its queries to server (host:port, the port
+// defaults to 53).
+func NewResolver(server string) Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: dnsTimeout}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+var resolver = NewResolver("")
+
+// isNotFound tells whether the error means that the name does not exist
+// (or has no record of the queried type) rather than a transient failure
+func isNotFound(err error) bool {
+	if dnsErr, ok := err.(*net.DNSError); ok {
+		return dnsErr.IsNotFound
+	}
+	return false
+}
@@ -1,18 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"net"
+	"strings"
 	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
 )
 
 var columns = []string{
-	"end", "source", "header_from", "count", "dkim", "spf",
+	"end", "service", "source", "header_from", "count", "dkim", "spf", "dkim_alignment", "spf_alignment", "alignment", "disposition",
 }
 
 const dateFormat = "Mon, 02 Jan 2006"
+const shortDateFormat = "2006-01-02"
 
 type Date time.Time
 
@@ -64,20 +67,20 @@ func (d *Date) UnmarshalJSON(b []byte) error {
 // to notify them about the failed delivery. Since bounce messages are automatic responses, they must be
 // sent to the MAIL FROM address of the envelope.
 type FeedbackResult struct {
-	SourceFile string `json:"source_file"`
-	OrgName    string `json:"org_name"`
-	ReportID   string `json:"report_id"`
-	Begin      Date   `json:"begin"`
-	End        Date   `json:"end"`
-	SourceIP   net.IP `json:"source_ip"`
-	Source     string `json:"source"`
-	Count      int    `json:"count"`
-	EnvelopeTo string `json:"envelope_to"`
-	HeaderFrom string `json:"header_from"`
-	DKIMResult string `json:"dkim"`
-	SPFResult  string `json:"spf"`
-	Reason     string `json:"reason"`
-	XML        []byte `json:"xml"`
+	// record of the report (identifiers, DMARC evaluation, auth_results)
+	*dmarc.Record
+	Report     *dmarc.Report `json:"-"`
+	SourceFile string        `json:"source_file"`
+	OrgName    string        `json:"org_name"`
+	ReportID   string        `json:"report_id"`
+	Begin      Date          `json:"begin"`
+	End        Date          `json:"end"`
+	// reverse DNS name of the source IP
+	Source  string `json:"source"`
+	Service string `json:"service"`
+	// when the identities of every scanned result were first seen (see
+	// indexFirstSeen)
+	firstSeen *firstSeenIndex
 }
 
 func (r *FeedbackResult) Columns() []string {
@@ -100,11 +103,39 @@ func (r *FeedbackResult) ToRow() []string {
 		if c == "source" && m[c] == "" {
 			m[c] = m["source_ip"]
 		}
+		// fallback to source
+		if c == "service" {
+			m[c] = r.ServiceName()
+		}
+		if c == "listed" {
+			m[c] = r.ListingStatus()
+		}
+		if domainFields[c] {
+			m[c] = strings.Join(displayDomains(fields[c](r)), ",")
+		}
+		// derived columns
+		if _, exists := m[c]; !exists {
+			if get, exists := fields[c]; exists {
+				m[c] = strings.Join(get(r), ",")
+			}
+		}
 		out[i] = fmt.Sprintf("%v", m[c])
 	}
 	return out
 }
 
+// ServiceName returns the name of the service that sent the messages,
+// falling back to the reverse DNS name of the source and then to its IP
+func (r *FeedbackResult) ServiceName() string {
+	if r.Service != "" {
+		return r.Service
+	}
+	if r.Source != "" {
+		return r.Source
+	}
+	return r.SourceIP.String()
+}
+
 type FeedbackResults []*FeedbackResult
 
 func (r FeedbackResults) Len() int {
@@ -134,96 +165,31 @@ func (r FeedbackResults) Swap(i, j int) {
 	r[i], r[j] = r[j], r[i]
 }
 
-// type domainResult struct {
-// 	dkim string
-// 	spf  string
-// }
-
-func parseFeedback(feedback *Feedback, sourceFile string) FeedbackResults {
-	results := make([]*FeedbackResult, 0)
-	var source string
-	reportID := feedback.Reportmetadata.Reportid
-	orgName := feedback.Reportmetadata.Orgname
-	begin := Date(time.Unix(int64(feedback.Reportmetadata.Daterange.Begin), 0))
-
-	end := Date(time.Unix(int64(feedback.Reportmetadata.Daterange.End), 0))
-
-	for _, record := range feedback.Record {
-		raw, err := xml.MarshalIndent(record, "", "  ")
-		if err != nil {
-			fmt.Println(err)
+// newResults turns the records of a report into results, resolving their
+// source and service
+func newResults(report *dmarc.Report) FeedbackResults {
+	results := make([]*FeedbackResult, 0, len(report.Records))
+	for _, record := range report.Records {
+		source := ""
+		if !offline && record.SourceIP != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+			names, err := resolver.LookupAddr(ctx, record.SourceIP.String())
+			cancel()
+			if err == nil && len(names) > 0 {
+				source = names[0]
+			}
 		}
-		// fmt.Println(string(raw))
-		sourceIP := net.ParseIP(record.Row.Sourceip)
-		names, err := net.LookupAddr(record.Row.Sourceip)
-		if err == nil {
-			source = names[0]
-		} else {
-			source = ""
-		}
-
-		results = append(
-			results,
-			&FeedbackResult{
-				SourceFile: sourceFile,
-				OrgName:    orgName,
-				ReportID:   reportID,
-				Begin:      begin,
-				End:        end,
-				SourceIP:   sourceIP,
-				Source:     source,
-				Count:      record.Row.Count,
-				EnvelopeTo: record.Identifiers.Envelopeto,
-				HeaderFrom: record.Identifiers.Headerfrom,
-				// Domain:     d,
-				SPFResult:  record.Row.Policyevaluated.Spf,
-				DKIMResult: record.Row.Policyevaluated.Dkim,
-				XML:        raw,
-			},
-		)
-		// spf
-
-		// m[row.Identifiers.Headerfrom] = &domainResult{}
-		// for _, spf := range row.Authresults.Spf {
-		// 	r, exists := m[spf.Domain]
-		// 	if exists {
-		// 		r.spf = spf.Result
-		// 	} else {
-		// 		m[spf.Domain] = &domainResult{spf: spf.Result}
-		// 	}
-		// }
-		// // dkim
-		// for _, dkim := range row.Authresults.Dkim {
-		// 	r, exists := m[dkim.Domain]
-		// 	if exists {
-		// 		r.dkim = dkim.Result
-		// 	} else {
-		// 		m[dkim.Domain] = &domainResult{dkim: dkim.Result}
-		// 	}
-		// }
-
-		// for d, r := range m {
-		// 	results = append(
-		// 		results,
-		// 		&FeedbackResult{
-		// 			SourceFile: sourceFile,
-		// 			OrgName:    orgName,
-		// 			ReportID:   reportID,
-		// 			Begin:      begin,
-		// 			End:        end,
-		// 			SourceIP:   sourceIP,
-		// 			Source:     source,
-		// 			Count:      row.Row.Count,
-		// 			EnvelopeTo: row.Identifiers.Envelopeto,
-		// 			HeaderFrom: row.Identifiers.Headerfrom,
-		// 			Domain:     d,
-		// 			SPFResult:  r.spf,
-		// 			DKIMResult: r.dkim,
-		// 		},
-		// 	)
-		// }
-
+		results = append(results, &FeedbackResult{
+			Record:     record,
+			Report:     report,
+			SourceFile: report.File,
+			OrgName:    report.OrgName,
+			ReportID:   report.ReportID,
+			Begin:      Date(report.Begin),
+			End:        Date(report.End),
+			Source:     source,
+			Service:    catalog.Match(record.SourceIP, source, record.DKIMDomains(true)),
+		})
 	}
-
 	return results
 }
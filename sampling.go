@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// samplingStats compares how the policy was applied to the enforceable
+// messages (failing DMARC with a quarantine or reject policy) to what the
+// pct of the policy predicts
+type samplingStats struct {
+	Key string
+	// pct values of the policies
+	Pcts        map[string]int
+	Enforceable int
+	// messages the requested disposition was applied to
+	Applied int
+	// messages reported as sampled_out, or with a more lenient disposition
+	// for another reason
+	SampledOut int
+	Overridden int
+	// estimations from the pct
+	EstApplied  float64
+	EstExempted float64
+}
+
+func (s *samplingStats) Add(r *FeedbackResult) {
+	if !r.Enforceable() {
+		return
+	}
+	s.Pcts[strconv.Itoa(r.Policy.Pct)] += r.Count
+	s.Enforceable += r.Count
+	switch {
+	case r.SampledOut():
+		s.SampledOut += r.Count
+	case r.Overridden():
+		s.Overridden += r.Count
+	default:
+		s.Applied += r.Count
+	}
+	applied, exempted := r.EstimateSampling()
+	s.EstApplied += applied
+	s.EstExempted += exempted
+}
+
+func (s *samplingStats) cells(name string) []string {
+	return []string{
+		name,
+		strings.Join(topKeys(s.Pcts), ", "),
+		strconv.Itoa(s.Enforceable),
+		strconv.Itoa(s.Applied),
+		strconv.Itoa(s.SampledOut),
+		strconv.Itoa(s.Overridden),
+		fmt.Sprintf("%.1f", s.EstApplied),
+		fmt.Sprintf("%.1f", s.EstExempted),
+	}
+}
+
+// samplingSummary shows, per policy domain and per day, how many failing
+// messages the policy was applied to or exempted from, as reported and as
+// estimated from the pct
+func samplingSummary(results FeedbackResults) ([]string, []summaryRow) {
+	domains := make(map[string]*samplingStats)
+	days := make(map[string]map[string]*samplingStats)
+	for _, r := range results {
+		d := r.Policy.Domain
+		if domains[d] == nil {
+			domains[d] = &samplingStats{Key: d, Pcts: make(map[string]int)}
+			days[d] = make(map[string]*samplingStats)
+		}
+		day := time.Time(r.End).Format(shortDateFormat)
+		if days[d][day] == nil {
+			days[d][day] = &samplingStats{Key: day, Pcts: make(map[string]int)}
+		}
+		domains[d].Add(r)
+		days[d][day].Add(r)
+	}
+	keys := make([]string, 0, len(domains))
+	for d := range domains {
+		keys = append(keys, d)
+	}
+	sort.Strings(keys)
+
+	rows := make([]summaryRow, 0, len(keys))
+	for _, d := range keys {
+		periods := make([]string, 0, len(days[d]))
+		for day := range days[d] {
+			periods = append(periods, day)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(periods)))
+		children := make([]summaryRow, 0, len(periods))
+		for _, day := range periods {
+			children = append(children, summaryRow{
+				cells: days[d][day].cells(day),
+				query: queryTerm("policy_domain", d) + " " + queryTerm("end", day) + " enforceable=yes",
+			})
+		}
+		rows = append(rows, summaryRow{
+			cells:    domains[d].cells(dmarc.DisplayDomain(d)),
+			query:    queryTerm("policy_domain", d) + " enforceable=yes",
+			children: children,
+		})
+	}
+	return []string{
+		"domain", "pct", "enforceable", "applied", "sampled out", "overridden", "est. applied", "est. exempted",
+	}, rows
+}
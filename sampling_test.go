@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestSamplingStats(t *testing.T) {
+	tests := []struct {
+		pct               int
+		applied, exempted float64
+	}{
+		{0, 0, 20},
+		{50, 10, 10},
+		// a report without pct parses as 100
+		{100, 20, 0},
+	}
+	for _, tt := range tests {
+		s := &samplingStats{Pcts: make(map[string]int)}
+		for _, disposition := range []string{"quarantine", "none"} {
+			s.Add(&FeedbackResult{Record: &dmarc.Record{
+				Count:       10,
+				HeaderFrom:  "example.com",
+				DKIMResult:  "fail",
+				SPFResult:   "fail",
+				Disposition: disposition,
+				Policy:      dmarc.Policy{Domain: "example.com", P: "quarantine", Pct: tt.pct},
+			}})
+		}
+		if s.Enforceable != 20 || s.Applied != 10 || s.Overridden != 10 {
+			t.Errorf("pct=%d: %d enforceable, %d applied, %d overridden", tt.pct, s.Enforceable, s.Applied, s.Overridden)
+		}
+		if s.EstApplied != tt.applied || s.EstExempted != tt.exempted {
+			t.Errorf("pct=%d: %.1f/%.1f estimated, want %.1f/%.1f", tt.pct, s.EstApplied, s.EstExempted, tt.applied, tt.exempted)
+		}
+		if len(s.Pcts) != 1 || s.Pcts[strconv.Itoa(tt.pct)] != 20 {
+			t.Errorf("pct=%d: pcts %v", tt.pct, s.Pcts)
+		}
+	}
+}
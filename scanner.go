@@ -2,6 +2,7 @@ package main
 
 import (
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/situation-sh/tmarc/dmarc"
 )
 
 // dummy component to fetch data
@@ -9,7 +10,12 @@ type scanner struct {
 	directory string
 }
 
-type ScanResultsMsg FeedbackResults
+// ScanResultsMsg carries the records and the reports of a scan
+type ScanResultsMsg struct {
+	results FeedbackResults
+	reports []*dmarc.Report
+}
+
 type ScanTriggerMsg string
 
 func NewScanner(directory string) scanner {
@@ -32,11 +38,11 @@ func (s scanner) View() string {
 	return ""
 }
 
-func (s scanner) rawScan() FeedbackResults {
-	fr := search(s.directory)
-	return fr
+func (s scanner) rawScan() (FeedbackResults, []*dmarc.Report) {
+	return search(s.directory)
 }
 
 func (s scanner) scan() tea.Msg {
-	return ScanResultsMsg(s.rawScan())
+	results, reports := s.rawScan()
+	return ScanResultsMsg{results: results, reports: reports}
 }
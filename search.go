@@ -1,34 +1,26 @@
 package main
 
 import (
-	"encoding/xml"
-	"io/fs"
-	"path/filepath"
 	"sort"
+
+	"github.com/situation-sh/tmarc/dmarc"
 )
 
-func search(dir string) FeedbackResults {
+// search reads the reports of a directory tree, it returns their records
+// and the reports themselves (some have no record)
+func search(dir string) (FeedbackResults, []*dmarc.Report) {
 	results := make(FeedbackResults, 0)
-	filepath.WalkDir(dir,
-		func(path string, info fs.DirEntry, err error) error {
-			if err != nil || info.IsDir() {
-				return nil
-			}
-			content, err := checkFile(path)
-			if err != nil {
-				return nil
-			}
-			feedback := Feedback{}
-
-			if err := xml.Unmarshal(content, &feedback); err != nil {
-				return nil
-			}
-			results = append(results, parseFeedback(&feedback, path)...)
-			return nil
-		},
-	)
+	reports := make([]*dmarc.Report, 0)
+	dmarc.Walk(dir, func(path string, report *dmarc.Report, err error) error {
+		// skip the files that are not reports
+		if err == nil {
+			reports = append(reports, report)
+			results = append(results, newResults(report)...)
+		}
+		return nil
+	})
 
 	// sort data in descending order (based on End)
 	sort.Sort(sort.Reverse(results))
-	return results
+	return results, reports
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// Sender returns the identity of the sender of the messages: the service
+// when known, else the organizational domain of the reverse DNS name of
+// the source, else its network
+func (r *FeedbackResult) Sender() string {
+	switch {
+	case r.Service != "":
+		return r.Service
+	case r.Source != "":
+		return dmarc.OrgDomain(r.Source)
+	}
+	return r.Network()
+}
+
+// senderKind tells how the sender was identified
+func (r *FeedbackResult) senderKind() string {
+	switch {
+	case r.Service != "":
+		return "service"
+	case r.Source != "":
+		return "rdns"
+	}
+	return "network"
+}
+
+// seen keeps the first and last day a group was reported
+type seen struct {
+	first, last time.Time
+}
+
+func (s *seen) Add(r *FeedbackResult) {
+	if b := time.Time(r.Begin); s.first.IsZero() || b.Before(s.first) {
+		s.first = b
+	}
+	if e := time.Time(r.End); e.After(s.last) {
+		s.last = e
+	}
+}
+
+// senderActivity is what the sender view shows of a sender, or of one of
+// its IPs, besides its stats
+type senderActivity struct {
+	reporters map[string]int
+	isNew     bool
+	seen
+}
+
+func (a *senderActivity) Add(r *FeedbackResult) {
+	a.reporters[r.OrgName] += r.Count
+	a.seen.Add(r)
+}
+
+// sendersSummary is the inventory of the senders, every sender expanding
+// into its source IPs
+func sendersSummary(results FeedbackResults) ([]string, []summaryRow) {
+	members := make(map[string]FeedbackResults)
+	kinds := make(map[string]string)
+	names := make(map[string]string)
+	// activity of the senders and of their IPs (key: sender|ip)
+	activities := make(map[string]*senderActivity)
+	activity := func(key string) *senderActivity {
+		a, exists := activities[key]
+		if !exists {
+			a = &senderActivity{reporters: make(map[string]int)}
+			activities[key] = a
+		}
+		return a
+	}
+	for _, r := range results {
+		sender, ip := r.Sender(), r.SourceIP.String()
+		members[sender] = append(members[sender], r)
+		kinds[sender] = r.senderKind()
+		names[ip] = r.Source
+		activity(sender).Add(r)
+		activity(sender + "|" + ip).Add(r)
+		for _, kind := range r.NewIdentities() {
+			switch kind {
+			case "sender":
+				activity(sender).isNew = true
+			case "ip":
+				activity(sender + "|" + ip).isNew = true
+			}
+		}
+	}
+
+	rows := make([]summaryRow, 0, len(members))
+	for _, g := range results.GroupBy(func(r *FeedbackResult) []string {
+		return []string{r.Sender()}
+	}) {
+		children := make([]summaryRow, 0)
+		ips := members[g.Key].GroupBy(func(r *FeedbackResult) []string {
+			return []string{r.SourceIP.String()}
+		})
+		for _, ip := range ips {
+			a := activities[g.Key+"|"+ip.Key]
+			cells := append([]string{ip.Key, newBadge(a.isNew), names[ip.Key]}, senderCells(ip, a)...)
+			children = append(children, summaryRow{cells: cells, query: queryTerm("source_ip", ip.Key)})
+		}
+		a := activities[g.Key]
+		cells := append([]string{g.Key, newBadge(a.isNew), kinds[g.Key]}, senderCells(g, a)...)
+		rows = append(rows, summaryRow{cells: cells, query: queryTerm("sender", g.Key), children: children})
+	}
+	cols := append([]string{"sender", "new", "identity"}, statsColumns...)
+	return append(cols, "domains", "reporters", "first seen", "last seen"), rows
+}
+
+// senderCells returns the cells of a sender, or of one of its IPs
+func senderCells(g *groupStats, a *senderActivity) []string {
+	return append(statsCells(&g.stats),
+		strings.Join(displayDomains(topKeys(g.Domains)), ", "),
+		strings.Join(topKeys(a.reporters), ", "),
+		a.first.Format(shortDateFormat),
+		a.last.Format(shortDateFormat),
+	)
+}
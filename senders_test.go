@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func TestSendersSummary(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	result := func(days int, ip, service, reporter string, count int) *FeedbackResult {
+		begin := day.AddDate(0, 0, days)
+		return &FeedbackResult{
+			Record: &dmarc.Record{
+				SourceIP:   net.ParseIP(ip),
+				Count:      count,
+				HeaderFrom: "example.com",
+			},
+			OrgName: reporter,
+			Begin:   Date(begin),
+			End:     Date(begin.Add(24 * time.Hour)),
+			Service: service,
+		}
+	}
+	results := FeedbackResults{
+		result(0, "192.0.2.1", "SendGrid", "google.com", 10),
+		result(3, "192.0.2.2", "SendGrid", "yahoo.com", 5),
+		result(1, "192.0.2.1", "SendGrid", "yahoo.com", 1),
+		result(2, "198.51.100.1", "", "google.com", 2),
+	}
+	cols, rows := sendersSummary(results)
+	cell := func(row summaryRow, col string) string {
+		for i, c := range cols {
+			if c == col {
+				return row.cells[i]
+			}
+		}
+		t.Fatalf("no %s column", col)
+		return ""
+	}
+	if len(rows) != 2 || rows[0].cells[0] != "SendGrid" || len(rows[0].children) != 2 {
+		t.Fatalf("rows %v", rows)
+	}
+	sendgrid, ip1, ip2 := rows[0], rows[0].children[0], rows[0].children[1]
+	tests := []struct {
+		row        summaryRow
+		col, value string
+	}{
+		{sendgrid, "messages", "16"},
+		{sendgrid, "reporters", "google.com, yahoo.com"},
+		{sendgrid, "first seen", "2024-03-01"},
+		{sendgrid, "last seen", "2024-03-05"},
+		{ip1, "sender", "192.0.2.1"},
+		{ip1, "reporters", "google.com, yahoo.com"},
+		{ip1, "last seen", "2024-03-03"},
+		{ip2, "reporters", "yahoo.com"},
+		{ip2, "first seen", "2024-03-04"},
+		{rows[1], "identity", "network"},
+	}
+	for _, tt := range tests {
+		if got := cell(tt.row, tt.col); got != tt.value {
+			t.Errorf("%s of %s: %q, want %q", tt.col, tt.row.cells[0], got, tt.value)
+		}
+	}
+}
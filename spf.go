@@ -0,0 +1,608 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// limits of RFC 7208 (section 4.6.4)
+const (
+	spfLookupLimit = 10
+	spfVoidLimit   = 2
+	spfMXLimit     = 10
+	spfPTRLimit    = 10
+)
+
+// SPFResult is the outcome of a local SPF evaluation (RFC 7208)
+type SPFResult struct {
+	Domain string
+	IP     net.IP
+	// none, neutral, pass, fail, softfail, temperror or permerror
+	Result string
+	// mechanism (with its qualifier) that decided the result
+	Mechanism string
+	// chain of records followed to reach the mechanism, starting with
+	// the evaluated domain (ex: example.com > include:_spf.google.com > ~all)
+	Path []string
+	// DNS lookups counted against the 10-lookup limit
+	Lookups     int
+	VoidLookups int
+	// explanation of errors
+	Reason string
+}
+
+// Authorized tells whether the IP is allowed to send for the domain
+func (s *SPFResult) Authorized() bool {
+	return s.Result == "pass"
+}
+
+// spfError aborts the evaluation with a temperror or a permerror
+type spfError struct {
+	result string
+	reason string
+}
+
+func (e *spfError) Error() string {
+	return e.result + ": " + e.reason
+}
+
+func permError(format string, args ...interface{}) *spfError {
+	return &spfError{result: "permerror", reason: fmt.Sprintf(format, args...)}
+}
+
+func tempError(format string, args ...interface{}) *spfError {
+	return &spfError{result: "temperror", reason: fmt.Sprintf(format, args...)}
+}
+
+type spfChecker struct {
+	ctx      context.Context
+	resolver Resolver
+	ip       net.IP
+	sender   string
+	lookups  int
+	void     int
+	match    []string
+}
+
+// CheckSPF evaluates the SPF policy of domain for the given source IP. The
+// local part of the sender is unknown from the reports, so the macros use
+// postmaster@domain (as for a null reverse-path).
+func CheckSPF(ctx context.Context, r Resolver, ip net.IP, domain string) *SPFResult {
+	domain = normalizeName(domain)
+	c := spfChecker{
+		ctx:      ctx,
+		resolver: r,
+		ip:       ip,
+		sender:   "postmaster@" + domain,
+	}
+	out := &SPFResult{Domain: domain, IP: ip}
+	if ip == nil {
+		out.Result = "permerror"
+		out.Reason = "invalid source IP"
+		return out
+	}
+	if domain == "" {
+		out.Result = "none"
+		out.Reason = "no SPF domain in the report"
+		return out
+	}
+
+	result, err := c.check(domain, []string{domain})
+	out.Lookups = c.lookups
+	out.VoidLookups = c.void
+	if err != nil {
+		out.Result = err.result
+		out.Reason = err.reason
+		return out
+	}
+	out.Result = result
+	if len(c.match) > 0 {
+		out.Path = c.match
+		out.Mechanism = c.match[len(c.match)-1]
+	} else {
+		out.Path = []string{domain}
+	}
+	if result == "none" {
+		out.Reason = "no SPF record"
+	} else if out.Mechanism == "" {
+		out.Reason = "no mechanism matched"
+	}
+	return out
+}
+
+// count registers a DNS querying term
+func (c *spfChecker) count() *spfError {
+	c.lookups++
+	if c.lookups > spfLookupLimit {
+		return permError("more than %d DNS lookups", spfLookupLimit)
+	}
+	return nil
+}
+
+// countVoid registers a lookup returning no answer
+func (c *spfChecker) countVoid() *spfError {
+	c.void++
+	if c.void > spfVoidLimit {
+		return permError("more than %d void DNS lookups", spfVoidLimit)
+	}
+	return nil
+}
+
+// fetch returns the SPF record of a domain ("" if there is none). The
+// lookup of an include or redirect target (target) that returns no answer
+// is a void lookup.
+func (c *spfChecker) fetch(domain string, target bool) (string, *spfError) {
+	txts, err := c.resolver.LookupTXT(c.ctx, domain)
+	if err == nil && len(txts) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	}
+	if err != nil {
+		if isNotFound(err) {
+			if target {
+				return "", c.countVoid()
+			}
+			return "", nil
+		}
+		return "", tempError("TXT lookup of %s: %v", domain, err)
+	}
+	records := make([]string, 0)
+	for _, txt := range txts {
+		lower := strings.ToLower(txt)
+		if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return "", nil
+	case 1:
+		return records[0], nil
+	default:
+		return "", permError("%s has %d SPF records", domain, len(records))
+	}
+}
+
+var modifierName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9\-_.]*$`)
+
+// check evaluates the SPF record of domain. It returns the result of the
+// record and c.match is filled with the path of the matching mechanism.
+func (c *spfChecker) check(domain string, path []string) (string, *spfError) {
+	record, err := c.fetch(domain, len(path) > 1)
+	if err != nil {
+		return "", err
+	}
+	if record == "" {
+		return "none", nil
+	}
+
+	redirect := ""
+	terms := strings.Fields(record)[1:]
+	mechanisms := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if i := strings.Index(term, "="); i > 0 && modifierName.MatchString(term[:i]) {
+			switch strings.ToLower(term[:i]) {
+			case "redirect":
+				if redirect != "" {
+					return "", permError("%s has several redirect modifiers", domain)
+				}
+				redirect = term[i+1:]
+			}
+			// exp and unknown modifiers are ignored
+			continue
+		}
+		mechanisms = append(mechanisms, term)
+	}
+
+	for _, term := range mechanisms {
+		qualifier := "+"
+		mechanism := term
+		if strings.ContainsAny(term[:1], "+-~?") {
+			qualifier = term[:1]
+			mechanism = term[1:]
+		}
+		matched, err := c.mechanism(domain, mechanism, path)
+		if err != nil {
+			return "", err
+		}
+		if matched {
+			if c.match == nil {
+				c.match = append(append([]string{}, path...), term)
+			}
+			return qualifierResult(qualifier), nil
+		}
+	}
+
+	if redirect != "" {
+		if err := c.count(); err != nil {
+			return "", err
+		}
+		target, err := c.expand(redirect, domain)
+		if err != nil {
+			return "", err
+		}
+		result, err := c.check(target, append(path, "redirect="+target))
+		if err != nil {
+			return "", err
+		}
+		if result == "none" {
+			return "", permError("redirect target %s has no SPF record", target)
+		}
+		return result, nil
+	}
+	return "neutral", nil
+}
+
+func qualifierResult(q string) string {
+	switch q {
+	case "-":
+		return "fail"
+	case "~":
+		return "softfail"
+	case "?":
+		return "neutral"
+	default:
+		return "pass"
+	}
+}
+
+// mechanism tells whether a mechanism (without qualifier) matches
+func (c *spfChecker) mechanism(domain, mechanism string, path []string) (bool, *spfError) {
+	name := mechanism
+	arg := ""
+	if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+		name = mechanism[:i]
+		arg = mechanism[i:]
+	}
+	name = strings.ToLower(name)
+
+	switch name {
+	case "all":
+		return true, nil
+
+	case "ip4", "ip6":
+		network, err := parseSPFNetwork(strings.TrimPrefix(arg, ":"), name == "ip4")
+		if err != nil {
+			return false, permError("%s: %v", mechanism, err)
+		}
+		return network.Contains(c.ip), nil
+
+	case "include":
+		if err := c.count(); err != nil {
+			return false, err
+		}
+		target, err := c.expand(strings.TrimPrefix(arg, ":"), domain)
+		if err != nil {
+			return false, err
+		}
+		result, err := c.check(target, append(path, "include:"+target))
+		if err != nil {
+			return false, err
+		}
+		if result != "pass" {
+			// forget the mechanism that decided the included record
+			c.match = nil
+		}
+		switch result {
+		case "pass":
+			return true, nil
+		case "none":
+			return false, permError("included domain %s has no SPF record", target)
+		default:
+			return false, nil
+		}
+
+	case "a", "mx":
+		if err := c.count(); err != nil {
+			return false, err
+		}
+		spec, v4, v6, cidrErr := splitDualCIDR(arg)
+		if cidrErr != nil {
+			return false, permError("%s: %v", mechanism, cidrErr)
+		}
+		var err *spfError
+		target := domain
+		if spec != "" {
+			if target, err = c.expand(spec, domain); err != nil {
+				return false, err
+			}
+		}
+		hosts := []string{target}
+		if name == "mx" {
+			if hosts, err = c.mxHosts(target); err != nil {
+				return false, err
+			}
+		}
+		for _, host := range hosts {
+			ips, err := c.lookupIP(host)
+			if err != nil {
+				return false, err
+			}
+			for _, ip := range ips {
+				if cidrMatch(c.ip, ip, v4, v6) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+
+	case "ptr":
+		if err := c.count(); err != nil {
+			return false, err
+		}
+		target := domain
+		if spec := strings.TrimPrefix(arg, ":"); spec != "" {
+			var err *spfError
+			if target, err = c.expand(spec, domain); err != nil {
+				return false, err
+			}
+		}
+		names, lookupErr := c.resolver.LookupAddr(c.ctx, c.ip.String())
+		if lookupErr != nil {
+			// errors during ptr are ignored (RFC 7208 5.5)
+			return false, nil
+		}
+		for i, n := range names {
+			if i >= spfPTRLimit {
+				break
+			}
+			if !hasDomainSuffix(normalizeName(n), target) {
+				continue
+			}
+			ips, err := c.resolver.LookupIPAddr(c.ctx, n)
+			if err != nil {
+				continue
+			}
+			for _, ip := range ips {
+				if ip.IP.Equal(c.ip) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+
+	case "exists":
+		if err := c.count(); err != nil {
+			return false, err
+		}
+		target, err := c.expand(strings.TrimPrefix(arg, ":"), domain)
+		if err != nil {
+			return false, err
+		}
+		ips, err := c.lookupIP(target)
+		if err != nil {
+			return false, err
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, permError("unknown mechanism %s", mechanism)
+}
+
+// mxHosts returns the exchangers of domain
+func (c *spfChecker) mxHosts(domain string) ([]string, *spfError) {
+	mxs, err := c.resolver.LookupMX(c.ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, c.countVoid()
+		}
+		return nil, tempError("MX lookup of %s: %v", domain, err)
+	}
+	if len(mxs) > spfMXLimit {
+		return nil, permError("%s has more than %d MX records", domain, spfMXLimit)
+	}
+	hosts := make([]string, len(mxs))
+	for i, mx := range mxs {
+		hosts[i] = mx.Host
+	}
+	return hosts, nil
+}
+
+// lookupIP returns the addresses (A and AAAA) of host
+func (c *spfChecker) lookupIP(host string) ([]net.IP, *spfError) {
+	addrs, err := c.resolver.LookupIPAddr(c.ctx, host)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, c.countVoid()
+		}
+		return nil, tempError("address lookup of %s: %v", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, c.countVoid()
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+	return ips, nil
+}
+
+// parseSPFNetwork parses the argument of ip4/ip6 mechanisms
+func parseSPFNetwork(arg string, v4 bool) (*net.IPNet, error) {
+	if !strings.Contains(arg, "/") {
+		if v4 {
+			arg += "/32"
+		} else {
+			arg += "/128"
+		}
+	}
+	ip, network, err := net.ParseCIDR(arg)
+	if err != nil {
+		return nil, err
+	}
+	if (ip.To4() != nil) != v4 {
+		return nil, fmt.Errorf("address family mismatch")
+	}
+	return network, nil
+}
+
+// splitDualCIDR splits the argument of a/mx mechanisms into the domain
+// spec and the IPv4 and IPv6 prefix lengths (ex: ":example.com/24//64")
+func splitDualCIDR(arg string) (string, int, int, error) {
+	v4, v6 := 32, 128
+	arg = strings.TrimPrefix(arg, ":")
+	spec := arg
+	if i := strings.Index(arg, "/"); i >= 0 {
+		spec = arg[:i]
+		cidrs := arg[i:]
+		if j := strings.Index(cidrs, "//"); j >= 0 {
+			n, err := strconv.Atoi(cidrs[j+2:])
+			if err != nil || n < 0 || n > 128 {
+				return "", 0, 0, fmt.Errorf("invalid ip6 cidr length")
+			}
+			v6 = n
+			cidrs = cidrs[:j]
+		}
+		if cidrs != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(cidrs, "/"))
+			if err != nil || n < 0 || n > 32 {
+				return "", 0, 0, fmt.Errorf("invalid ip4 cidr length")
+			}
+			v4 = n
+		}
+	}
+	return spec, v4, v6, nil
+}
+
+// cidrMatch compares the client IP with a record IP of the same family
+func cidrMatch(client, record net.IP, v4, v6 int) bool {
+	if c4, r4 := client.To4(), record.To4(); c4 != nil || r4 != nil {
+		if c4 == nil || r4 == nil {
+			return false
+		}
+		mask := net.CIDRMask(v4, 32)
+		return c4.Mask(mask).Equal(r4.Mask(mask))
+	}
+	mask := net.CIDRMask(v6, 128)
+	return client.Mask(mask).Equal(record.Mask(mask))
+}
+
+// uppercase macro letters are URL-escaped
+var macroPattern = regexp.MustCompile(`^([slodiphcrtvSLODIPHCRTV])([0-9]*)([rR]?)([.\-+,/_=]*)$`)
+
+// expand replaces the macros of a domain spec (RFC 7208 section 7)
+func (c *spfChecker) expand(spec, domain string) (string, *spfError) {
+	if !strings.Contains(spec, "%") {
+		return normalizeName(spec), nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			b.WriteByte(spec[i])
+			continue
+		}
+		if i+1 >= len(spec) {
+			return "", permError("invalid macro in %s", spec)
+		}
+		i++
+		switch spec[i] {
+		case '%':
+			b.WriteByte('%')
+		case '_':
+			b.WriteByte(' ')
+		case '-':
+			b.WriteString("%20")
+		case '{':
+			end := strings.Index(spec[i:], "}")
+			if end < 0 {
+				return "", permError("unterminated macro in %s", spec)
+			}
+			m := macroPattern.FindStringSubmatch(spec[i+1 : i+end])
+			if m == nil {
+				return "", permError("invalid macro in %s", spec)
+			}
+			b.WriteString(c.macro(m[1], m[2], m[3] != "", m[4], domain))
+			i += end
+		default:
+			return "", permError("invalid macro in %s", spec)
+		}
+	}
+	return normalizeName(b.String()), nil
+}
+
+// macro returns the value of a single macro letter with its transformers
+func (c *spfChecker) macro(letter, digits string, reverse bool, delimiters, domain string) string {
+	local, senderDomain, _ := strings.Cut(c.sender, "@")
+	var value string
+	switch strings.ToLower(letter) {
+	case "s":
+		value = c.sender
+	case "l":
+		value = local
+	case "o":
+		value = senderDomain
+	case "d", "h":
+		value = domain
+	case "i":
+		value = dottedIP(c.ip)
+	case "c":
+		// readable form: dotted for IPv4, colons for IPv6
+		value = c.ip.String()
+	case "p":
+		value = "unknown"
+	case "v":
+		value = "in-addr"
+		if c.ip.To4() == nil {
+			value = "ip6"
+		}
+	case "r":
+		value = "unknown"
+	case "t":
+		value = "0"
+	}
+	if delimiters == "" {
+		delimiters = "."
+	}
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(delimiters, r)
+	})
+	if reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+	if n, err := strconv.Atoi(digits); err == nil && n > 0 && n < len(parts) {
+		parts = parts[len(parts)-n:]
+	}
+	value = strings.Join(parts, ".")
+	if letter != strings.ToLower(letter) {
+		value = urlEscape(value)
+	}
+	return value
+}
+
+// urlEscape escapes the characters outside of the unreserved set of RFC
+// 3986 (RFC 7208 section 7.3)
+func urlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '.', ch == '_', ch == '~':
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// dottedIP returns an IPv4 as is and an IPv6 as dot-separated nibbles
+func dottedIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	nibbles := make([]string, 0, 32)
+	for _, b := range ip.To16() {
+		nibbles = append(nibbles, strconv.FormatInt(int64(b>>4), 16), strconv.FormatInt(int64(b&0xf), 16))
+	}
+	return strings.Join(nibbles, ".")
+}
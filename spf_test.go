@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// stubResolver answers from static zones, the missing names being
+// NXDOMAIN and the names of fail being server failures
+type stubResolver struct {
+	txt  map[string][]string
+	ip   map[string][]string
+	mx   map[string][]string
+	ptr  map[string][]string
+	fail map[string]bool
+}
+
+func (s *stubResolver) answer(name string, zone map[string][]string) ([]string, error) {
+	name = normalizeName(name)
+	if s.fail[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	values, exists := zone[name]
+	if !exists {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return values, nil
+}
+
+func (s *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return s.answer(name, s.txt)
+}
+
+func (s *stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	values, err := s.answer(host, s.ip)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]net.IPAddr, len(values))
+	for i, v := range values {
+		out[i] = net.IPAddr{IP: net.ParseIP(v)}
+	}
+	return out, nil
+}
+
+func (s *stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	values, err := s.answer(name, s.mx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*net.MX, len(values))
+	for i, v := range values {
+		out[i] = &net.MX{Host: v, Pref: uint16(10 * i)}
+	}
+	return out, nil
+}
+
+func (s *stubResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	values, exists := s.ptr[addr]
+	if !exists {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return values, nil
+}
+
+// includeChain returns records where example.com includes n domains one
+// after the other, the last one allowing 192.0.2.0/24
+func includeChain(n int) map[string][]string {
+	txt := map[string][]string{"example.com": {"v=spf1 include:i1.test -all"}}
+	for i := 1; i < n; i++ {
+		txt[fmt.Sprintf("i%d.test", i)] = []string{fmt.Sprintf("v=spf1 include:i%d.test -all", i+1)}
+	}
+	txt[fmt.Sprintf("i%d.test", n)] = []string{"v=spf1 ip4:192.0.2.0/24 -all"}
+	return txt
+}
+
+func TestCheckSPF(t *testing.T) {
+	tests := []struct {
+		name      string
+		dns       *stubResolver
+		ip        string
+		result    string
+		mechanism string
+		lookups   int
+		void      int
+	}{
+		{
+			name:      "ip4",
+			dns:       &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 ip4:192.0.2.0/24 -all"}}},
+			ip:        "192.0.2.1",
+			result:    "pass",
+			mechanism: "ip4:192.0.2.0/24",
+		},
+		{
+			name:      "ip6",
+			dns:       &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 ip6:2001:db8::/32 ~all"}}},
+			ip:        "2001:db8::25",
+			result:    "pass",
+			mechanism: "ip6:2001:db8::/32",
+		},
+		{
+			name:      "all",
+			dns:       &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 ip4:192.0.2.0/24 ~all"}}},
+			ip:        "198.51.100.1",
+			result:    "softfail",
+			mechanism: "~all",
+		},
+		{
+			name:   "no record",
+			dns:    &stubResolver{txt: map[string][]string{"example.com": {"google-site-verification=x"}}},
+			ip:     "192.0.2.1",
+			result: "none",
+		},
+		{
+			name:   "several records",
+			dns:    &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 -all", "v=spf1 +all"}}},
+			ip:     "192.0.2.1",
+			result: "permerror",
+		},
+		{
+			name: "include",
+			dns: &stubResolver{txt: map[string][]string{
+				"example.com":   {"v=spf1 include:_spf.esp.test -all"},
+				"_spf.esp.test": {"v=spf1 ip4:192.0.2.0/24 -all"},
+			}},
+			ip:        "192.0.2.1",
+			result:    "pass",
+			mechanism: "ip4:192.0.2.0/24",
+			lookups:   1,
+		},
+		{
+			name: "include not matching",
+			dns: &stubResolver{txt: map[string][]string{
+				"example.com":   {"v=spf1 include:_spf.esp.test -all"},
+				"_spf.esp.test": {"v=spf1 ip4:192.0.2.0/24 -all"},
+			}},
+			ip:        "198.51.100.1",
+			result:    "fail",
+			mechanism: "-all",
+			lookups:   1,
+		},
+		{
+			name:    "include without record",
+			dns:     &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 include:missing.test -all"}}},
+			ip:      "192.0.2.1",
+			result:  "permerror",
+			lookups: 1,
+			void:    1,
+		},
+		{
+			name: "include temperror",
+			dns: &stubResolver{
+				txt:  map[string][]string{"example.com": {"v=spf1 include:broken.test -all"}},
+				fail: map[string]bool{"broken.test": true},
+			},
+			ip:      "192.0.2.1",
+			result:  "temperror",
+			lookups: 1,
+		},
+		{
+			name: "redirect",
+			dns: &stubResolver{txt: map[string][]string{
+				"example.com":      {"v=spf1 redirect=_spf.example.net"},
+				"_spf.example.net": {"v=spf1 a:mail.example.net -all"},
+			}, ip: map[string][]string{"mail.example.net": {"192.0.2.7"}}},
+			ip:        "192.0.2.7",
+			result:    "pass",
+			mechanism: "a:mail.example.net",
+			lookups:   2,
+		},
+		{
+			name: "redirect after mechanisms",
+			dns: &stubResolver{txt: map[string][]string{
+				"example.com":      {"v=spf1 ip4:198.51.100.0/24 redirect=_spf.example.net"},
+				"_spf.example.net": {"v=spf1 -all"},
+			}},
+			ip:        "198.51.100.1",
+			result:    "pass",
+			mechanism: "ip4:198.51.100.0/24",
+		},
+		{
+			name:    "redirect without record",
+			dns:     &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 redirect=missing.test"}}},
+			ip:      "192.0.2.1",
+			result:  "permerror",
+			lookups: 1,
+			void:    1,
+		},
+		{
+			name: "mx",
+			dns: &stubResolver{
+				txt: map[string][]string{"example.com": {"v=spf1 mx/24 -all"}},
+				mx:  map[string][]string{"example.com": {"mx1.example.com", "mx2.example.com"}},
+				ip:  map[string][]string{"mx1.example.com": {"198.51.100.1"}, "mx2.example.com": {"192.0.2.10"}},
+			},
+			ip:        "192.0.2.200",
+			result:    "pass",
+			mechanism: "mx/24",
+			lookups:   1,
+		},
+		{
+			name:      "lookup limit",
+			dns:       &stubResolver{txt: includeChain(10)},
+			ip:        "192.0.2.1",
+			result:    "pass",
+			mechanism: "ip4:192.0.2.0/24",
+			lookups:   10,
+		},
+		{
+			name:    "lookup limit exceeded",
+			dns:     &stubResolver{txt: includeChain(11)},
+			ip:      "192.0.2.1",
+			result:  "permerror",
+			lookups: 11,
+		},
+		{
+			name:      "void lookups",
+			dns:       &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 a:v1.test a:v2.test ?all"}}},
+			ip:        "192.0.2.1",
+			result:    "neutral",
+			mechanism: "?all",
+			lookups:   2,
+			void:      2,
+		},
+		{
+			name:    "void lookup limit exceeded",
+			dns:     &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 a:v1.test a:v2.test a:v3.test ?all"}}},
+			ip:      "192.0.2.1",
+			result:  "permerror",
+			lookups: 3,
+			void:    3,
+		},
+		{
+			name: "exists macro",
+			dns: &stubResolver{
+				txt: map[string][]string{"example.com": {"v=spf1 exists:%{ir}.%{v}._spf.%{d} -all"}},
+				ip:  map[string][]string{"1.2.0.192.in-addr._spf.example.com": {"127.0.0.2"}},
+			},
+			ip:        "192.0.2.1",
+			result:    "pass",
+			mechanism: "exists:%{ir}.%{v}._spf.%{d}",
+			lookups:   1,
+		},
+		{
+			name:   "unknown mechanism",
+			dns:    &stubResolver{txt: map[string][]string{"example.com": {"v=spf1 ip5:192.0.2.1 -all"}}},
+			ip:     "192.0.2.1",
+			result: "permerror",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckSPF(context.Background(), tt.dns, net.ParseIP(tt.ip), "example.com")
+			if got.Result != tt.result {
+				t.Fatalf("result %s (%s), want %s", got.Result, got.Reason, tt.result)
+			}
+			if got.Mechanism != tt.mechanism {
+				t.Errorf("mechanism %q, want %q", got.Mechanism, tt.mechanism)
+			}
+			if got.Lookups != tt.lookups || got.VoidLookups != tt.void {
+				t.Errorf("lookups %d/%d void, want %d/%d", got.Lookups, got.VoidLookups, tt.lookups, tt.void)
+			}
+		})
+	}
+}
+
+func TestCheckSPFPath(t *testing.T) {
+	dns := &stubResolver{txt: map[string][]string{
+		"example.com":      {"v=spf1 include:_spf.esp.test -all"},
+		"_spf.esp.test":    {"v=spf1 redirect=_spf2.esp.test"},
+		"_spf2.esp.test":   {"v=spf1 ip4:203.0.113.0/24 -all"},
+		"_unused.esp.test": {"v=spf1 +all"},
+	}}
+	got := CheckSPF(context.Background(), dns, net.ParseIP("203.0.113.9"), "example.com")
+	want := "example.com > include:_spf.esp.test > redirect=_spf2.esp.test > ip4:203.0.113.0/24"
+	if path := strings.Join(got.Path, " > "); path != want {
+		t.Errorf("path %q, want %q", path, want)
+	}
+}
+
+func TestSPFMacros(t *testing.T) {
+	tests := []struct {
+		ip   string
+		spec string
+		want string
+	}{
+		{"192.0.2.1", "%{i}.%{d}", "192.0.2.1.mail.example.com"},
+		{"192.0.2.1", "%{ir}.%{v}.arpa", "1.2.0.192.in-addr.arpa"},
+		{"192.0.2.1", "%{d2}", "example.com"},
+		{"192.0.2.1", "%{d1r}", "mail"},
+		{"192.0.2.1", "%{l}.%{o}", "postmaster.example.com"},
+		{"192.0.2.1", "%{s}", "postmaster@example.com"},
+		{"192.0.2.1", "%{S}", "postmaster%40example.com"},
+		{"192.0.2.1", "%{L-}.x", "postmaster.x"},
+		{"192.0.2.1", "a%%b%_c", "a%b c"},
+		{"2001:db8::1", "%{ir}.%{v}", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6"},
+		{"2001:db8::1", "%{i}", "2.0.0.1.0.d.b.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1"},
+		{"2001:db8::1", "%{c}", "2001:db8::1"},
+		{"192.0.2.1", "%{c}", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		c := spfChecker{ip: net.ParseIP(tt.ip), sender: "postmaster@example.com"}
+		got, err := c.expand(tt.spec, "mail.example.com")
+		if err != nil {
+			t.Errorf("expand(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expand(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"%{x}", "%{d", "%"} {
+		c := spfChecker{ip: net.ParseIP("192.0.2.1"), sender: "postmaster@example.com"}
+		if _, err := c.expand(spec, "example.com"); err == nil {
+			t.Errorf("expand(%q): no error", spec)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// Spoofed tells whether the messages used the domain without any
+// authentication: both DKIM and SPF failed, even for an unaligned domain
+// (transient errors and forwarded traffic aside). The messages passing for
+// an unaligned domain are left to the alignment view.
+func (r *FeedbackResult) Spoofed() bool {
+	return r.Alignment() == dmarc.AuthFail && !r.Transient() && !r.Forwarded()
+}
+
+// spoofedResults returns the spoofed results
+func spoofedResults(results FeedbackResults) FeedbackResults {
+	out := make(FeedbackResults, 0)
+	for _, r := range results {
+		if r.Spoofed() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// spoofingSource is the spoofed traffic of a source IP
+type spoofingSource struct {
+	IP           string
+	Results      FeedbackResults
+	Messages     int
+	Dispositions map[string]int
+	Reporters    map[string]int
+	Domains      map[string]int
+	seen
+}
+
+// spoofingSources groups the spoofed results per source, the biggest
+// sources first
+func spoofingSources(results FeedbackResults) []*spoofingSource {
+	index := make(map[string]*spoofingSource)
+	for _, r := range spoofedResults(results) {
+		ip := r.SourceIP.String()
+		s := index[ip]
+		if s == nil {
+			s = &spoofingSource{
+				IP:           ip,
+				Dispositions: make(map[string]int),
+				Reporters:    make(map[string]int),
+				Domains:      make(map[string]int),
+			}
+			index[ip] = s
+		}
+		s.Results = append(s.Results, r)
+		s.Messages += r.Count
+		s.Dispositions[r.Disposition] += r.Count
+		s.Reporters[r.OrgName] += r.Count
+		s.Domains[r.HeaderFrom] += r.Count
+		s.Add(r)
+	}
+	out := make([]*spoofingSource, 0, len(index))
+	for _, s := range index {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages == out[j].Messages {
+			return out[i].IP < out[j].IP
+		}
+		return out[i].Messages > out[j].Messages
+	})
+	return out
+}
+
+// blocked returns the messages that were quarantined or rejected
+func (s *spoofingSource) blocked() int {
+	return s.Dispositions["quarantine"] + s.Dispositions["reject"]
+}
+
+// spoofingSummary ranks the sources of spoofed messages
+func spoofingSummary(results FeedbackResults) ([]string, []summaryRow) {
+	sources := spoofingSources(results)
+	rows := make([]summaryRow, 0, len(sources))
+	for _, s := range sources {
+		r := s.Results[0]
+		dispositions := make([]string, 0, len(s.Dispositions))
+		for _, d := range topKeys(s.Dispositions) {
+			dispositions = append(dispositions, d+" "+percent(s.Dispositions[d], s.Messages))
+		}
+		country := ""
+		if info := r.ASN(); info != nil {
+			country = info.Country
+		}
+		rows = append(rows, summaryRow{cells: []string{
+			s.IP,
+			r.Source,
+			r.Network(),
+			r.ASN().String(),
+			country,
+			strconv.Itoa(len(s.Results)),
+			strconv.Itoa(s.Messages),
+			percent(s.blocked(), s.Messages),
+			strings.Join(dispositions, ", "),
+			strings.Join(displayDomains(topKeys(s.Domains)), ", "),
+			strings.Join(topKeys(s.Reporters), ", "),
+			s.first.Format(shortDateFormat),
+			s.last.Format(shortDateFormat),
+		}, query: queryTerm("source_ip", s.IP) + " spoofed=yes"})
+	}
+	return []string{"source_ip", "source", "network", "asn", "country", "records", "messages", "blocked", "disposition", "domains", "reporters", "first seen", "last seen"}, rows
+}
+
+// spoofingTrend draws the trend of the spoofed messages of the selected
+// source over the period of the spoofed messages
+func spoofingTrend(all, selected FeedbackResults) string {
+	return trendDetail(spoofedResults(all), spoofedResults(selected))
+}
+
+// evidence is a spoofed record, as listed for abuse reporting
+type evidence struct {
+	Rank        int    `json:"rank"`
+	SourceIP    string `json:"source_ip"`
+	Source      string `json:"source"`
+	ASN         string `json:"asn"`
+	ASName      string `json:"as_name"`
+	Country     string `json:"country"`
+	Begin       string `json:"begin"`
+	End         string `json:"end"`
+	Reporter    string `json:"reporter"`
+	ReportID    string `json:"report_id"`
+	HeaderFrom  string `json:"header_from"`
+	EnvelopeTo  string `json:"envelope_to"`
+	SPFDomain   string `json:"spf_domain"`
+	Count       int    `json:"count"`
+	DKIM        string `json:"dkim"`
+	SPF         string `json:"spf"`
+	Disposition string `json:"disposition"`
+}
+
+var evidenceColumns = []string{"rank", "source_ip", "source", "asn", "as_name", "country", "begin", "end", "reporter", "report_id", "header_from", "envelope_to", "spf_domain", "count", "dkim", "spf", "disposition"}
+
+func (e *evidence) row() []string {
+	return []string{strconv.Itoa(e.Rank), e.SourceIP, e.Source, e.ASN, e.ASName, e.Country, e.Begin, e.End,
+		e.Reporter, e.ReportID, e.HeaderFrom, e.EnvelopeTo, e.SPFDomain, strconv.Itoa(e.Count), e.DKIM, e.SPF, e.Disposition}
+}
+
+// evidenceList lists the spoofed records, per source (ranked by volume)
+// and in chronological order
+func evidenceList(results FeedbackResults) []*evidence {
+	out := make([]*evidence, 0)
+	for rank, s := range spoofingSources(results) {
+		records := append(FeedbackResults{}, s.Results...)
+		sort.SliceStable(records, func(i, j int) bool {
+			return time.Time(records[i].Begin).Before(time.Time(records[j].Begin))
+		})
+		for _, r := range records {
+			e := &evidence{
+				Rank:        rank + 1,
+				SourceIP:    s.IP,
+				Source:      r.Source,
+				Begin:       time.Time(r.Begin).UTC().Format(time.RFC3339),
+				End:         time.Time(r.End).UTC().Format(time.RFC3339),
+				Reporter:    r.OrgName,
+				ReportID:    r.ReportID,
+				HeaderFrom:  r.HeaderFrom,
+				EnvelopeTo:  r.EnvelopeTo,
+				SPFDomain:   r.SPFDomain(),
+				Count:       r.Count,
+				DKIM:        r.DKIMResult,
+				SPF:         r.SPFResult,
+				Disposition: r.Disposition,
+			}
+			if info := r.ASN(); info != nil {
+				e.ASN, e.ASName, e.Country = info.String(), info.Name, info.Country
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// writeEvidence writes the evidence list as CSV, or JSON
+func writeEvidence(out io.Writer, list []*evidence, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	w := csv.NewWriter(out)
+	w.Write(evidenceColumns)
+	for _, e := range list {
+		w.Write(e.row())
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// spoofingCommand prints the evidence list as CSV (or JSON)
+func spoofingCommand(results FeedbackResults, reports []*dmarc.Report, f *Filter) int {
+	if err := writeEvidence(os.Stdout, evidenceList(results.Filter(f)), jsonOutput); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
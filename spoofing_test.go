@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+func spoofingResults() FeedbackResults {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	result := func(days int, ip, reporter, disposition string, count int, dkim []*dmarc.DKIMAuth, spf dmarc.Result) *FeedbackResult {
+		begin := day.AddDate(0, 0, days)
+		return &FeedbackResult{
+			Record: &dmarc.Record{
+				SourceIP:    net.ParseIP(ip),
+				Count:       count,
+				Disposition: disposition,
+				DKIMResult:  "fail",
+				SPFResult:   "fail",
+				HeaderFrom:  "example.com",
+				DKIMAuth:    dkim,
+				SPFAuth:     []*dmarc.SPFAuth{{Domain: "example.net", Result: spf}},
+				Policy:      dmarc.Policy{Domain: "example.com", P: "reject", Pct: 100},
+			},
+			OrgName:  reporter,
+			ReportID: reporter + "-1",
+			Begin:    Date(begin),
+			End:      Date(begin.Add(24 * time.Hour)),
+		}
+	}
+	forwarded := result(0, "192.0.2.50", "google.com", "none", 40, nil, dmarc.ResultFail)
+	forwarded.Reasons = []*dmarc.OverrideReason{{Type: "forwarded"}}
+	aligned := result(0, "192.0.2.60", "google.com", "none", 50, []*dmarc.DKIMAuth{{Domain: "example.com", Result: dmarc.ResultPass}}, dmarc.ResultFail)
+	aligned.DKIMResult = "pass"
+	return FeedbackResults{
+		result(2, "192.0.2.1", "google.com", "reject", 5, nil, dmarc.ResultFail),
+		result(1, "192.0.2.1", "yahoo.com", "none", 3, nil, dmarc.ResultSoftFail),
+		result(0, "198.51.100.1", "google.com", "quarantine", 10, nil, dmarc.ResultFail),
+		// passed for a domain of its own, an unaligned pass
+		result(0, "203.0.113.1", "google.com", "none", 100, nil, dmarc.ResultPass),
+		// transient error
+		result(0, "203.0.113.2", "google.com", "none", 100, nil, dmarc.ResultTempError),
+		forwarded,
+		aligned,
+	}
+}
+
+func TestSpoofingSources(t *testing.T) {
+	sources := spoofingSources(spoofingResults())
+	if len(sources) != 2 {
+		t.Fatalf("%d sources, want 2", len(sources))
+	}
+	first, second := sources[0], sources[1]
+	if first.IP != "198.51.100.1" || first.Messages != 10 || first.blocked() != 10 {
+		t.Errorf("first source %s: %d messages, %d blocked", first.IP, first.Messages, first.blocked())
+	}
+	if second.IP != "192.0.2.1" || second.Messages != 8 || second.blocked() != 5 || len(second.Results) != 2 {
+		t.Errorf("second source %s: %d messages, %d blocked, %d records", second.IP, second.Messages, second.blocked(), len(second.Results))
+	}
+	if want := map[string]int{"google.com": 5, "yahoo.com": 3}; !reflect.DeepEqual(second.Reporters, want) {
+		t.Errorf("reporters %v, want %v", second.Reporters, want)
+	}
+	if got := second.first.Format(shortDateFormat); got != "2024-03-02" {
+		t.Errorf("first seen %s", got)
+	}
+}
+
+func TestEvidenceList(t *testing.T) {
+	list := evidenceList(spoofingResults())
+	got := make([]string, len(list))
+	for i, e := range list {
+		got[i] = e.SourceIP + " " + e.Reporter + " " + e.Begin
+	}
+	want := []string{
+		"198.51.100.1 google.com 2024-03-01T00:00:00Z",
+		"192.0.2.1 yahoo.com 2024-03-02T00:00:00Z",
+		"192.0.2.1 google.com 2024-03-03T00:00:00Z",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("evidence %v, want %v", got, want)
+	}
+	if list[1].Rank != 2 || list[1].SPF != "fail" || list[1].SPFDomain != "example.net" || list[1].Disposition != "none" {
+		t.Errorf("evidence %+v", list[1])
+	}
+}
+
+func TestWriteEvidence(t *testing.T) {
+	list := evidenceList(spoofingResults())
+
+	var b bytes.Buffer
+	if err := writeEvidence(&b, list, false); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(list)+1 || !reflect.DeepEqual(rows[0], evidenceColumns) {
+		t.Fatalf("%d rows, header %v", len(rows), rows[0])
+	}
+	if len(rows[1]) != len(evidenceColumns) || rows[1][1] != "198.51.100.1" || rows[1][13] != "10" {
+		t.Errorf("first row %v", rows[1])
+	}
+
+	b.Reset()
+	if err := writeEvidence(&b, list, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(list) {
+		t.Fatalf("%d JSON records, want %d", len(decoded), len(list))
+	}
+	// the JSON keys are the CSV columns
+	for _, c := range evidenceColumns {
+		if _, exists := decoded[0][c]; !exists {
+			t.Errorf("JSON record without %s", c)
+		}
+	}
+	if len(decoded[0]) != len(evidenceColumns) {
+		t.Errorf("JSON record with %d keys, want %d", len(decoded[0]), len(evidenceColumns))
+	}
+}
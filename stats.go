@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// stats accumulates message counts (not records) of a group of results
+type stats struct {
+	Records   int
+	Messages  int
+	DKIMPass  int
+	SPFPass   int
+	DMARCPass int
+	// messages that failed because of a transient error (temperror), they
+	// are left out of the pass rates
+	Transient int
+	// messages likely forwarded or sent through a mailing list
+	Forwarded int
+}
+
+func (s *stats) Add(r *FeedbackResult) {
+	s.Records++
+	s.Messages += r.Count
+	if r.Transient() {
+		s.Transient += r.Count
+	}
+	if r.Forwarded() {
+		s.Forwarded += r.Count
+	}
+	dkim := r.DKIMResult == "pass"
+	spf := r.SPFResult == "pass"
+	if dkim {
+		s.DKIMPass += r.Count
+	}
+	if spf {
+		s.SPFPass += r.Count
+	}
+	if r.Pass() {
+		s.DMARCPass += r.Count
+	}
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*rate(n, total))
+}
+
+// rated returns the messages the pass rates are computed on
+func (s *stats) rated() int { return s.Messages - s.Transient }
+
+func (s *stats) DKIMRate() string  { return percent(s.DKIMPass, s.rated()) }
+func (s *stats) SPFRate() string   { return percent(s.SPFPass, s.rated()) }
+func (s *stats) DMARCRate() string { return percent(s.DMARCPass, s.rated()) }
+
+// groupStats is a group of results sharing the same key
+type groupStats struct {
+	Key     string
+	Domains map[string]int // messages per header_from
+	stats
+}
+
+// GroupBy aggregates the results by the given key. A result may belong to
+// several groups (or none if key returns nothing). Groups are sorted by
+// descending message volume.
+func (r FeedbackResults) GroupBy(key func(*FeedbackResult) []string) []*groupStats {
+	index := make(map[string]*groupStats)
+	for _, x := range r {
+		for _, k := range key(x) {
+			g, exists := index[k]
+			if !exists {
+				g = &groupStats{Key: k, Domains: make(map[string]int)}
+				index[k] = g
+			}
+			g.Add(x)
+			g.Domains[x.HeaderFrom] += x.Count
+		}
+	}
+	out := make([]*groupStats, 0, len(index))
+	for _, g := range index {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages == out[j].Messages {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].Messages > out[j].Messages
+	})
+	return out
+}
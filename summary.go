@@ -0,0 +1,382 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/situation-sh/tmarc/dmarc"
+)
+
+// summaryRow is a row of an aggregated view
+type summaryRow struct {
+	cells []string
+	// query selecting the records behind the row (drill-down)
+	query string
+	// rows displayed below the row when it is expanded
+	children []summaryRow
+}
+
+// summaryBuilder turns results into table columns and rows
+type summaryBuilder func(results FeedbackResults) ([]string, []summaryRow)
+
+// reportsBuilder is a summaryBuilder taking the reports without record
+// matching the filter as well (see reportsWithoutRecords)
+type reportsBuilder func(results FeedbackResults, empty []*dmarc.Report) ([]string, []summaryRow)
+
+// summaryFetcher returns a command that gathers extra data (DNS...) for
+// the view. The command must return a RefreshViewMsg once done.
+type summaryFetcher func(results FeedbackResults) tea.Cmd
+
+// summaryDetail describes the records of the selected row (selected), all
+// being the results of the view
+type summaryDetail func(all, selected FeedbackResults) string
+
+// RefreshViewMsg asks to rebuild the current view
+type RefreshViewMsg struct{}
+
+// DrillDownMsg asks to show the records matching the query
+type DrillDownMsg string
+
+// view is an aggregated view of the results (the records view aside)
+type view interface {
+	Name() string
+	SetResults(results FeedbackResults)
+	SetSize(width, height int)
+	// Fetch returns the command gathering the extra data of the view
+	Fetch(results FeedbackResults) tea.Cmd
+	Update(msg tea.Msg) (view, tea.Cmd)
+	View() string
+}
+
+// reportsView is a view showing the reports without record as well, they
+// are set before the results
+type reportsView interface {
+	SetReports(empty []*dmarc.Report)
+}
+
+// tabular is a view whose rows can be expanded and sorted
+type tabular interface {
+	view
+	toggle()
+	nextSort()
+}
+
+// component displaying an aggregated view of the results as a table
+type summary struct {
+	name     string
+	build    summaryBuilder
+	fetch    summaryFetcher
+	detail   summaryDetail
+	table    *table.Model
+	height   int
+	results  FeedbackResults
+	empty    []*dmarc.Report
+	rows     []summaryRow
+	expanded map[string]bool
+	// column the rows are sorted on (-1 keeps the order of the builder)
+	sortBy  int
+	reverse bool
+	// detail of the selected row, computed when the selection or the
+	// results change
+	detailQuery string
+	detailText  string
+}
+
+func NewSummary(name string, build summaryBuilder) *summary {
+	t := table.New(table.WithStyles(tableStyle()), table.WithHeight(10))
+	return &summary{
+		name:     name,
+		build:    build,
+		table:    &t,
+		height:   10,
+		expanded: make(map[string]bool),
+		sortBy:   -1,
+	}
+}
+
+// NewReportsSummary is NewSummary for a builder of the reports without
+// record as well
+func NewReportsSummary(name string, build reportsBuilder) *summary {
+	s := NewSummary(name, nil)
+	s.build = func(results FeedbackResults) ([]string, []summaryRow) {
+		return build(results, s.empty)
+	}
+	return s
+}
+
+// SetReports sets the reports without record of the next SetResults
+func (s *summary) SetReports(empty []*dmarc.Report) {
+	s.empty = empty
+}
+
+// WithFetcher attaches a data fetcher to the summary
+func (s *summary) WithFetcher(fetch summaryFetcher) *summary {
+	s.fetch = fetch
+	return s
+}
+
+func (s *summary) Name() string {
+	return s.name
+}
+
+// WithDetail displays a description of the selected row below the table
+func (s *summary) WithDetail(detail summaryDetail) *summary {
+	s.detail = detail
+	s.SetSize(0, s.height)
+	return s
+}
+
+// Fetch returns the command gathering the extra data of the view (if any)
+func (s summary) Fetch(results FeedbackResults) tea.Cmd {
+	if s.fetch == nil {
+		return nil
+	}
+	return s.fetch(results)
+}
+
+// SetResults rebuilds the table (the columns of a table cannot be changed
+// once created)
+func (s *summary) SetResults(results FeedbackResults) {
+	s.results = results
+	cursor := s.table.Cursor()
+	cols, rows := s.build(results)
+	if s.sortBy >= len(cols) {
+		s.sortBy = -1
+	}
+	if s.sortBy >= 0 {
+		k := s.sortBy
+		sort.SliceStable(rows, func(i, j int) bool {
+			if s.reverse {
+				return cellLess(rows[j].cells[k], rows[i].cells[k])
+			}
+			return cellLess(rows[i].cells[k], rows[j].cells[k])
+		})
+		cols = append([]string{}, cols...)
+		if s.reverse {
+			cols[k] += " ↑"
+		} else {
+			cols[k] += " ↓"
+		}
+	}
+
+	// flatten the expanded rows
+	s.rows = make([]summaryRow, 0, len(rows))
+	for _, r := range rows {
+		expandable := len(r.children) > 0
+		if expandable {
+			mark := "▸ "
+			if s.expanded[r.cells[0]] {
+				mark = "▾ "
+			}
+			r.cells[0] = mark + r.cells[0]
+		}
+		s.rows = append(s.rows, r)
+		if expandable && s.expanded[strings.TrimPrefix(r.cells[0], "▾ ")] {
+			for _, c := range r.children {
+				c.cells[0] = "  " + c.cells[0]
+				s.rows = append(s.rows, c)
+			}
+		}
+	}
+
+	cells := make([][]string, len(s.rows))
+	for i, r := range s.rows {
+		cells[i] = append([]string{}, r.cells...)
+	}
+	columns, tableRows := buildTable(cols, cells)
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows(tableRows),
+		table.WithHeight(s.height),
+		table.WithStyles(tableStyle()),
+		table.WithFocused(true),
+	)
+	if cursor < len(tableRows) {
+		t.SetCursor(cursor)
+	}
+	s.table = &t
+	s.refreshDetail(true)
+}
+
+// refreshDetail computes the detail of the selected row, again if force is
+// set or the selection changed
+func (s *summary) refreshDetail(force bool) {
+	if s.detail == nil {
+		return
+	}
+	r := s.selected()
+	if r == nil {
+		s.detailQuery, s.detailText = "", ""
+		return
+	}
+	if !force && r.query == s.detailQuery {
+		return
+	}
+	s.detailQuery, s.detailText = r.query, ""
+	if f, err := ParseFilter(r.query); err == nil {
+		s.detailText = s.detail(s.results, s.results.Filter(f))
+	}
+}
+
+func (s *summary) SetSize(width, height int) {
+	s.height = height
+	if s.detail != nil {
+		// room for the detail line
+		s.height--
+	}
+	s.table.SetHeight(s.height)
+}
+
+// selected returns the row under the cursor
+func (s summary) selected() *summaryRow {
+	i := s.table.Cursor()
+	if i < 0 || i >= len(s.rows) {
+		return nil
+	}
+	return &s.rows[i]
+}
+
+// toggle expands or collapses the selected row
+func (s *summary) toggle() {
+	r := s.selected()
+	if r == nil || len(r.children) == 0 {
+		return
+	}
+	k := strings.TrimPrefix(strings.TrimPrefix(r.cells[0], "▸ "), "▾ ")
+	s.expanded[k] = !s.expanded[k]
+	s.SetResults(s.results)
+}
+
+// cellNumber parses the leading number of a cell ("12", "34%", "12 (34%)")
+func cellNumber(cell string) (float64, error) {
+	if fields := strings.Fields(cell); len(fields) > 0 {
+		cell = fields[0]
+	}
+	return strconv.ParseFloat(strings.TrimSuffix(cell, "%"), 64)
+}
+
+// cellLess orders the cells of a column: numbers (and percentages) first,
+// the highest first, then the text in alphabetical order, empty cells last
+func cellLess(a, b string) bool {
+	x, errA := cellNumber(a)
+	y, errB := cellNumber(b)
+	emptyA, emptyB := a == "" || a == "-", b == "" || b == "-"
+	switch {
+	case errA == nil && errB == nil:
+		return x > y
+	case errA == nil || errB == nil:
+		return errA == nil
+	case emptyA || emptyB:
+		return !emptyA
+	}
+	return a < b
+}
+
+// nextSort sorts the rows on the next column, back to the order of the
+// builder after the last one
+func (s *summary) nextSort() {
+	s.sortBy++
+	if len(s.rows) > 0 && s.sortBy >= len(s.rows[0].cells) {
+		s.sortBy = -1
+	}
+	s.SetResults(s.results)
+}
+
+func (s summary) Init() tea.Cmd {
+	return nil
+}
+
+func (s *summary) Update(msg tea.Msg) (view, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case " ":
+			s.toggle()
+			return s, nil
+		case "o":
+			s.nextSort()
+			return s, nil
+		case "O":
+			s.reverse = !s.reverse
+			s.SetResults(s.results)
+			return s, nil
+		case "enter":
+			if r := s.selected(); r != nil && r.query != "" {
+				query := r.query
+				return s, func() tea.Msg { return DrillDownMsg(query) }
+			}
+			return s, nil
+		}
+	}
+	t, cmd := s.table.Update(msg)
+	s.table = &t
+	s.refreshDetail(false)
+	return s, cmd
+}
+
+func (s summary) View() string {
+	v := RenderTable(s.table)
+	if s.detail != nil && s.selected() != nil {
+		v += "\n" + s.detailText
+	}
+	return v
+}
+
+// queryTerm builds a filter term, quoting the value if needed
+func queryTerm(field, value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return field + "=\"" + value + "\""
+	}
+	return field + "=" + value
+}
+
+// statsCells returns the common cells of aggregated rows
+func statsCells(s *stats) []string {
+	return []string{
+		strconv.Itoa(s.Records),
+		strconv.Itoa(s.Messages),
+		s.DMARCRate(),
+		s.DKIMRate(),
+		s.SPFRate(),
+		strconv.Itoa(s.Transient),
+		strconv.Itoa(s.Forwarded),
+	}
+}
+
+var statsColumns = []string{"records", "messages", "dmarc", "dkim", "spf", "transient", "forwarded"}
+
+// servicesSummary is the per-service compliance summary
+func servicesSummary(results FeedbackResults) ([]string, []summaryRow) {
+	groups := results.GroupBy(func(r *FeedbackResult) []string {
+		return []string{r.ServiceName()}
+	})
+	// listed source IPs per service
+	listed := make(map[string]map[string]bool)
+	for _, r := range results {
+		if len(r.Listings()) == 0 {
+			continue
+		}
+		if listed[r.ServiceName()] == nil {
+			listed[r.ServiceName()] = make(map[string]bool)
+		}
+		listed[r.ServiceName()][r.SourceIP.String()] = true
+	}
+
+	rows := make([]summaryRow, 0, len(groups))
+	for _, g := range groups {
+		row := append([]string{g.Key}, statsCells(&g.stats)...)
+		row = append(row, strconv.Itoa(len(g.Domains)))
+		if len(blocklists) > 0 {
+			row = append(row, strconv.Itoa(len(listed[g.Key])))
+		}
+		rows = append(rows, summaryRow{cells: row, query: queryTerm("service", g.Key)})
+	}
+	cols := append([]string{"service"}, statsColumns...)
+	cols = append(cols, "domains")
+	if len(blocklists) > 0 {
+		cols = append(cols, "listed")
+	}
+	return cols, rows
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCellLess(t *testing.T) {
+	cells := []string{"", "b", "9 (10%)", "-", "12 (34%)", "a", "50%", "", "3"}
+	sort.SliceStable(cells, func(i, j int) bool { return cellLess(cells[i], cells[j]) })
+	want := "50%|12 (34%)|9 (10%)|3|a|b||-|"
+	if got := strings.Join(cells, "|"); got != want {
+		t.Errorf("sorted %q, want %q", got, want)
+	}
+	if cellLess("", "") || cellLess("-", "") || cellLess("", "-") {
+		t.Error("empty cells are not equal")
+	}
+}
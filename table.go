@@ -5,6 +5,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 )
 
 const maxColWidth = 22
@@ -32,18 +33,26 @@ func toTable(results FeedbackResults) ([]table.Column, []table.Row) {
 		return []table.Column{}, []table.Row{}
 	}
 	r := results[0]
-	cols := r.Columns()
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, r.ToRow())
+	}
+	return buildTable(r.Columns(), rows)
+}
 
-	rows := make([]table.Row, 0)
+// buildTable turns raw cells into table columns and rows, truncating the
+// cells that are too wide
+func buildTable(cols []string, cells [][]string) ([]table.Column, []table.Row) {
+	rows := make([]table.Row, 0, len(cells))
 	columns := make([]table.Column, len(cols))
 
 	widths := make([]int, len(cols))
-	for _, r := range results {
-		r := r.ToRow()
+	for _, r := range cells {
 		for k, s := range r {
-			ls := len(s)
+			// display width, not bytes (multi-byte and wide characters)
+			ls := runewidth.StringWidth(s)
 			if ls > maxColWidth {
-				r[k] = s[:(maxColWidth-1)] + "…"
+				r[k] = runewidth.Truncate(s, maxColWidth, "…")
 				widths[k] = maxColWidth
 			} else if ls > widths[k] {
 				widths[k] = ls
@@ -72,10 +81,18 @@ func toTable(results FeedbackResults) ([]table.Column, []table.Row) {
 
 func NewTable(results FeedbackResults) table.Model {
 	columns, rows := toTable(results)
+	// fit the columns (with their padding)
+	width := 0
+	for _, c := range columns {
+		width += c.Width + 2
+	}
+	if width < 80 {
+		width = 80
+	}
 	return table.New(
 		table.WithColumns(columns),
 		table.WithRows(rows),
-		table.WithWidth(80),
+		table.WithWidth(width),
 		table.WithHeight(10),
 		table.WithStyles(tableStyle()),
 	)